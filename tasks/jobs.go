@@ -0,0 +1,101 @@
+package tasks
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/juju/errors"
+)
+
+// JobInfo describes a running job.
+type JobInfo struct {
+	ID      string
+	Started time.Time
+}
+
+type job struct {
+	info   JobInfo
+	cancel context.CancelFunc
+}
+
+// JobManager runs jobs, such as transcriptions, which can be cancelled
+// individually.
+type JobManager struct {
+	mu   sync.Mutex
+	jobs map[string]job
+}
+
+// NewJobManager returns a JobManager without any jobs.
+func NewJobManager() *JobManager {
+	return &JobManager{jobs: make(map[string]job)}
+}
+
+// Start runs fn in a new goroutine and returns the id of the job. The context
+// passed to fn is cancelled when the job is cancelled with Cancel.
+func (m *JobManager) Start(fn func(ctx context.Context) error) string {
+	ctx, cancel := context.WithCancel(context.Background())
+	id := generateID(20)
+
+	m.mu.Lock()
+	m.jobs[id] = job{
+		info:   JobInfo{ID: id, Started: time.Now()},
+		cancel: cancel,
+	}
+	m.mu.Unlock()
+	log.WithField("job", id).Info("Job started")
+
+	go func() {
+		defer m.finish(id)
+		if err := fn(ctx); err != nil {
+			log.WithFields(log.Fields{
+				"job":   id,
+				"error": errors.ErrorStack(err),
+			}).Error("Job failed")
+			return
+		}
+		log.WithField("job", id).Info("Job succeeded")
+	}()
+	return id
+}
+
+// finish removes a job once it returns.
+func (m *JobManager) finish(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if j, ok := m.jobs[id]; ok {
+		j.cancel()
+		delete(m.jobs, id)
+	}
+}
+
+// Cancel cancels the context of the job with the given id. It returns false
+// if no such job is running.
+func (m *JobManager) Cancel(id string) bool {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok {
+		return false
+	}
+	log.WithField("job", id).Info("Job cancelled")
+	j.cancel()
+	return true
+}
+
+// List returns the running jobs, the oldest first.
+func (m *JobManager) List() []JobInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	infos := make([]JobInfo, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		infos = append(infos, j.info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Started.Before(infos[j].Started)
+	})
+	return infos
+}
@@ -0,0 +1,58 @@
+package tasks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobManagerCancelsOneJob(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewJobManager()
+	cancelled := make(chan error, 1)
+	first := m.Start(func(ctx context.Context) error {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return ctx.Err()
+	})
+	release := make(chan struct{})
+	second := m.Start(func(ctx context.Context) error {
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	ids := []string{}
+	for _, info := range m.List() {
+		ids = append(ids, info.ID)
+	}
+	assert.Equal([]string{first, second}, ids)
+
+	assert.True(m.Cancel(first))
+	select {
+	case err := <-cancelled:
+		assert.Equal(context.Canceled, err)
+	case <-time.After(time.Second):
+		t.Fatal("job was not cancelled")
+	}
+	for start := time.Now(); len(m.List()) != 1 && time.Since(start) < time.Second; {
+		time.Sleep(time.Millisecond)
+	}
+	jobs := m.List()
+	if assert.Len(jobs, 1) {
+		assert.Equal(second, jobs[0].ID)
+	}
+
+	close(release)
+	for start := time.Now(); len(m.List()) != 0 && time.Since(start) < time.Second; {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Empty(m.List())
+	assert.False(m.Cancel(second))
+}
@@ -3,6 +3,7 @@ package tasks
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -13,7 +14,7 @@ func TestTaskErrorLeadsToErrorStatus(t *testing.T) {
 		return errors.New("This is the error text.")
 	}
 
-	ex := NewTaskExecuter()
+	ex := NewTaskExecuter(time.Hour)
 	id := ex.QueueTask(errorTask, func(a, b string) {})
 	status := ex.GetTaskStatus(id)
 	for status == INPROGRESS {
@@ -28,7 +29,7 @@ func TestTaskPanicLeadsToErrorStatus(t *testing.T) {
 		panic("AHHH!!!")
 	}
 
-	ex := NewTaskExecuter()
+	ex := NewTaskExecuter(time.Hour)
 	id := ex.QueueTask(errorTask, func(a, b string) {})
 	status := ex.GetTaskStatus(id)
 	for status == INPROGRESS {
@@ -43,7 +44,7 @@ func TestTaskOkLeadsToSuccessStatus(t *testing.T) {
 		return nil
 	}
 
-	ex := NewTaskExecuter()
+	ex := NewTaskExecuter(time.Hour)
 	id := ex.QueueTask(errorTask, func(a, b string) {})
 	status := ex.GetTaskStatus(id)
 	for status == INPROGRESS {
@@ -60,7 +61,7 @@ func TestInProgressStatus(t *testing.T) {
 		return nil
 	}
 
-	ex := NewTaskExecuter()
+	ex := NewTaskExecuter(time.Hour)
 	id := ex.QueueTask(errorTask, func(a, b string) {})
 	status := ex.GetTaskStatus(id)
 	assert.Equal(INPROGRESS, status)
@@ -0,0 +1,149 @@
+package transcription
+
+import "strings"
+
+// AlignmentKind tells how a word of a script was spoken.
+type AlignmentKind string
+
+const (
+	// Aligned is a script word which was spoken, maybe with a small
+	// difference such as "colour" for "color".
+	Aligned AlignmentKind = "aligned"
+	// Substituted is a script word for which another word was spoken.
+	Substituted AlignmentKind = "substituted"
+	// Missing is a script word which was not spoken.
+	Missing AlignmentKind = "missing"
+	// Extra is a spoken word which is not in the script.
+	Extra AlignmentKind = "extra"
+)
+
+// AlignedSegment is a word of a script aligned to the word spoken for it.
+type AlignedSegment struct {
+	Kind AlignmentKind
+	// ScriptWord is the word of the script, or empty if Kind is Extra.
+	ScriptWord string
+	// ScriptIndex is the position of ScriptWord among the words of the
+	// script, or -1 if Kind is Extra.
+	ScriptIndex int
+	// SpokenWord is the word of the transcript, or empty if Kind is Missing.
+	SpokenWord string
+	// Start and End are the time of SpokenWord in seconds. A missing word
+	// has the end of the spoken word before it as both Start and End.
+	Start float64
+	End   float64
+}
+
+// AlignToScript aligns the final words of res to the words of script, such
+// as to check an audiobook against its text. The words are aligned with the
+// fewest substitutions, missing and extra words, and words which differ in
+// case, punctuation or a letter or two count as the same. The segments are
+// in order of the script, with extra words where they were spoken.
+func AlignToScript(res *IBMResult, script string) []AlignedSegment {
+	spoken := res.words()
+	words := strings.Fields(script)
+
+	// cost[i][j] is the cost of aligning the first i script words to the
+	// first j spoken words
+	cost := make([][]int, len(words)+1)
+	for i := range cost {
+		cost[i] = make([]int, len(spoken)+1)
+		cost[i][0] = i
+	}
+	for j := range cost[0] {
+		cost[0][j] = j
+	}
+	for i := 1; i <= len(words); i++ {
+		for j := 1; j <= len(spoken); j++ {
+			substitution := 1
+			if similarWords(words[i-1], spoken[j-1].Word) {
+				substitution = 0
+			}
+			cost[i][j] = minInt(cost[i-1][j-1]+substitution, cost[i-1][j]+1, cost[i][j-1]+1)
+		}
+	}
+
+	// walk back from the end, preferring pairs over gaps
+	segments := []AlignedSegment{}
+	i, j := len(words), len(spoken)
+	for i > 0 || j > 0 {
+		switch {
+		case i > 0 && j > 0 && cost[i][j] == cost[i-1][j-1] && similarWords(words[i-1], spoken[j-1].Word):
+			segments = append(segments, spokenSegment(Aligned, words[i-1], i-1, spoken[j-1]))
+			i, j = i-1, j-1
+		case i > 0 && j > 0 && cost[i][j] == cost[i-1][j-1]+1:
+			segments = append(segments, spokenSegment(Substituted, words[i-1], i-1, spoken[j-1]))
+			i, j = i-1, j-1
+		case i > 0 && cost[i][j] == cost[i-1][j]+1:
+			at := 0.0
+			if j > 0 {
+				at = spoken[j-1].EndTime
+			}
+			segments = append(segments, AlignedSegment{Kind: Missing, ScriptWord: words[i-1], ScriptIndex: i - 1, Start: at, End: at})
+			i--
+		default:
+			segments = append(segments, spokenSegment(Extra, "", -1, spoken[j-1]))
+			j--
+		}
+	}
+	for left, right := 0, len(segments)-1; left < right; left, right = left+1, right-1 {
+		segments[left], segments[right] = segments[right], segments[left]
+	}
+	return segments
+}
+
+// spokenSegment returns the segment of a spoken word.
+func spokenSegment(kind AlignmentKind, scriptWord string, scriptIndex int, word timestamp) AlignedSegment {
+	return AlignedSegment{
+		Kind:        kind,
+		ScriptWord:  scriptWord,
+		ScriptIndex: scriptIndex,
+		SpokenWord:  word.Word,
+		Start:       word.StartTime,
+		End:         word.EndTime,
+	}
+}
+
+// similarWords returns whether a and b are the same word apart from case,
+// surrounding punctuation and at most one edit for every five letters.
+func similarWords(a, b string) bool {
+	a, b = normalizeWord(a), normalizeWord(b)
+	if a == b {
+		return true
+	}
+	longest := len([]rune(a))
+	if n := len([]rune(b)); n > longest {
+		longest = n
+	}
+	return editDistance(a, b) <= longest/5
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			substitution := 1
+			if ra[i-1] == rb[j-1] {
+				substitution = 0
+			}
+			current[j] = minInt(previous[j-1]+substitution, previous[j]+1, current[j-1]+1)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func minInt(first int, rest ...int) int {
+	for _, n := range rest {
+		if n < first {
+			first = n
+		}
+	}
+	return first
+}
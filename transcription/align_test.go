@@ -0,0 +1,40 @@
+package transcription
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlignToScriptReportsMissingWord(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{{"call", 0.0, 0.4}, {"me", 0.4, 0.6}},
+		[]ibmWordTimestamp{{"some", 1.5, 1.9}, {"years", 1.9, 2.3}, {"ago", 2.3, 2.7}},
+	)
+
+	segments := AlignToScript(res, "Call me Ishmael. Some years ago,")
+	assert.Equal([]AlignedSegment{
+		{Kind: Aligned, ScriptWord: "Call", ScriptIndex: 0, SpokenWord: "call", Start: 0.0, End: 0.4},
+		{Kind: Aligned, ScriptWord: "me", ScriptIndex: 1, SpokenWord: "me", Start: 0.4, End: 0.6},
+		{Kind: Missing, ScriptWord: "Ishmael.", ScriptIndex: 2, Start: 0.6, End: 0.6},
+		{Kind: Aligned, ScriptWord: "Some", ScriptIndex: 3, SpokenWord: "some", Start: 1.5, End: 1.9},
+		{Kind: Aligned, ScriptWord: "years", ScriptIndex: 4, SpokenWord: "years", Start: 1.9, End: 2.3},
+		{Kind: Aligned, ScriptWord: "ago,", ScriptIndex: 5, SpokenWord: "ago", Start: 2.3, End: 2.7},
+	}, segments)
+}
+
+func TestAlignToScriptReportsSubstitutedAndExtraWords(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult([]ibmWordTimestamp{
+		{"the", 0.0, 0.2}, {"colour", 0.2, 0.6}, {"um", 0.6, 0.8}, {"read", 0.8, 1.0},
+	})
+
+	kinds := []AlignmentKind{}
+	for _, segment := range AlignToScript(res, "the color red") {
+		kinds = append(kinds, segment.Kind)
+	}
+	assert.Equal([]AlignmentKind{Aligned, Aligned, Extra, Substituted}, kinds)
+}
@@ -0,0 +1,138 @@
+package transcription
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// AudioInfo contains metadata about an audio file.
+type AudioInfo struct {
+	Format     string // "wav" or "flac"
+	SampleRate int
+	Channels   int
+	BitDepth   int
+	Duration   time.Duration
+}
+
+// GetAudioInfo parses the header of the WAV or FLAC file at filePath.
+func GetAudioInfo(filePath string) (AudioInfo, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return AudioInfo{}, errors.Trace(err)
+	}
+	defer file.Close()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(file, magic); err != nil {
+		return AudioInfo{}, errors.Trace(err)
+	}
+
+	switch string(magic) {
+	case "RIFF":
+		info, err := parseWavHeader(file)
+		return info, errors.Trace(err)
+	case "fLaC":
+		info, err := parseFlacHeader(file)
+		return info, errors.Trace(err)
+	}
+	return AudioInfo{}, errors.Errorf("%s is not a WAV or FLAC file", filePath)
+}
+
+// parseWavHeader parses a WAV file whose "RIFF" magic number has been read.
+func parseWavHeader(r io.Reader) (AudioInfo, error) {
+	var riff struct {
+		Size   uint32
+		Format [4]byte
+	}
+	if err := binary.Read(r, binary.LittleEndian, &riff); err != nil {
+		return AudioInfo{}, errors.Trace(err)
+	}
+	if string(riff.Format[:]) != "WAVE" {
+		return AudioInfo{}, errors.New("RIFF file is not a WAV file")
+	}
+
+	info := AudioInfo{Format: "wav"}
+	var byteRate uint32
+	for {
+		var chunk struct {
+			ID   [4]byte
+			Size uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &chunk); err != nil {
+			return AudioInfo{}, errors.Annotate(err, "WAV file has no data chunk")
+		}
+
+		switch string(chunk.ID[:]) {
+		case "fmt ":
+			var format struct {
+				AudioFormat   uint16
+				Channels      uint16
+				SampleRate    uint32
+				ByteRate      uint32
+				BlockAlign    uint16
+				BitsPerSample uint16
+			}
+			if err := binary.Read(r, binary.LittleEndian, &format); err != nil {
+				return AudioInfo{}, errors.Trace(err)
+			}
+			info.Channels = int(format.Channels)
+			info.SampleRate = int(format.SampleRate)
+			info.BitDepth = int(format.BitsPerSample)
+			byteRate = format.ByteRate
+			// skip any format extension
+			if _, err := io.CopyN(ioutil.Discard, r, int64(chunk.Size)-16); err != nil {
+				return AudioInfo{}, errors.Trace(err)
+			}
+		case "data":
+			if byteRate == 0 {
+				return AudioInfo{}, errors.New("WAV data chunk precedes fmt chunk")
+			}
+			info.Duration = time.Duration(float64(chunk.Size) / float64(byteRate) * float64(time.Second))
+			return info, nil
+		default:
+			// chunks are padded to an even size
+			if _, err := io.CopyN(ioutil.Discard, r, int64(chunk.Size+chunk.Size%2)); err != nil {
+				return AudioInfo{}, errors.Trace(err)
+			}
+		}
+	}
+}
+
+// parseFlacHeader parses a FLAC file whose "fLaC" magic number has been read.
+// The STREAMINFO block is always the first metadata block.
+func parseFlacHeader(r io.Reader) (AudioInfo, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return AudioInfo{}, errors.Trace(err)
+	}
+	if header[0]&0x7f != 0 {
+		return AudioInfo{}, errors.New("FLAC file does not start with STREAMINFO")
+	}
+
+	streamInfo := make([]byte, 34)
+	if _, err := io.ReadFull(r, streamInfo); err != nil {
+		return AudioInfo{}, errors.Trace(err)
+	}
+
+	// bytes 10-17 hold 20 bits of sample rate, 3 bits of channels - 1, 5 bits
+	// of bits per sample - 1 and 36 bits of total samples
+	packed := binary.BigEndian.Uint64(streamInfo[10:18])
+	sampleRate := int(packed >> 44)
+	totalSamples := packed & (1<<36 - 1)
+
+	info := AudioInfo{
+		Format:     "flac",
+		SampleRate: sampleRate,
+		Channels:   int(packed>>41&0x7) + 1,
+		BitDepth:   int(packed>>36&0x1f) + 1,
+	}
+	if sampleRate > 0 {
+		info.Duration = time.Duration(float64(totalSamples) / float64(sampleRate) * float64(time.Second))
+	}
+	return info, nil
+}
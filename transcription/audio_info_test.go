@@ -0,0 +1,43 @@
+package transcription
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAudioInfoWav(t *testing.T) {
+	assert := assert.New(t)
+
+	info, err := GetAudioInfo("test.wav")
+	assert.NoError(err)
+	assert.Equal(AudioInfo{
+		Format:     "wav",
+		SampleRate: 8000,
+		Channels:   1,
+		BitDepth:   16,
+		Duration:   250 * time.Millisecond,
+	}, info)
+}
+
+func TestGetAudioInfoFlac(t *testing.T) {
+	assert := assert.New(t)
+
+	info, err := GetAudioInfo("test.flac")
+	assert.NoError(err)
+	assert.Equal(AudioInfo{
+		Format:     "flac",
+		SampleRate: 44100,
+		Channels:   2,
+		BitDepth:   16,
+		Duration:   2 * time.Second,
+	}, info)
+}
+
+func TestGetAudioInfoReturnsErrorForOtherFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := GetAudioInfo("test.json")
+	assert.Error(err)
+}
@@ -0,0 +1,96 @@
+package transcription
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/juju/errors"
+)
+
+// BatchOptions contains optional settings for TranscribeBatch.
+type BatchOptions struct {
+	// IBMOptions are used to transcribe every file.
+	IBMOptions IBMOptions
+	// Cache stores the transcript of every file under the SHA-256 hash of its
+	// content, if it is set. A file whose hash already has a transcript is not
+	// transcribed again, even if it was renamed.
+	Cache Store
+}
+
+// TranscribeBatch transcribes the files at filePaths one after the other and
+// returns their transcripts by path. A file which cannot be transcribed does
+// not stop the batch. Its error is logged and the first such error, a
+// *TranscribeError unless the cache failed, is returned along with the
+// transcripts of the other files.
+func TranscribeBatch(ctx context.Context, filePaths []string, creds IBMCredentials, opts BatchOptions) (map[string]*Transcript, error) {
+	transcripts := map[string]*Transcript{}
+	var firstErr error
+	for _, filePath := range filePaths {
+		if ctx.Err() != nil {
+			return transcripts, errors.Trace(ctx.Err())
+		}
+		transcript, err := transcribeBatchFile(ctx, filePath, creds, opts)
+		if err != nil {
+			log.Warnf("Could not transcribe %s: %v", filePath, err)
+			if firstErr == nil {
+				firstErr = annotatePhase(err, "could not transcribe %s", filePath)
+			}
+			continue
+		}
+		transcripts[filePath] = transcript
+	}
+	return transcripts, firstErr
+}
+
+// transcribeBatchFile transcribes the file at filePath, or returns its
+// transcript from opts.Cache.
+func transcribeBatchFile(ctx context.Context, filePath string, creds IBMCredentials, opts BatchOptions) (*Transcript, error) {
+	var hash string
+	if opts.Cache != nil {
+		var err error
+		if hash, err = fileHash(filePath); err != nil {
+			return nil, &TranscribeError{Path: filePath, Err: errors.Trace(err)}
+		}
+		transcript, err := opts.Cache.Load(ctx, hash)
+		if err == nil {
+			log.Debugf("Using the cached transcript of %s", filePath)
+			return transcript, nil
+		}
+		if !errors.IsNotFound(err) {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	res, err := transcribeFile(ctx, filePath, creds, opts.IBMOptions)
+	if err != nil {
+		return nil, tracePhase(err)
+	}
+	transcript := res.ToTranscript()
+	transcript.Metadata = NewTranscriptMetadata(filePath, opts.IBMOptions.model())
+	if opts.Cache != nil {
+		if err := opts.Cache.Save(ctx, hash, transcript); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return transcript, nil
+}
+
+// fileHash returns the SHA-256 hash of the content of the file at filePath,
+// formatted as sha256:<hex>.
+func fileHash(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", errors.Trace(err)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
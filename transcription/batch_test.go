@@ -0,0 +1,67 @@
+package transcription
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranscribeBatchReusesCachedTranscripts(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "batch")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	first := filepath.Join(dir, "first.flac")
+	second := filepath.Join(dir, "second.flac")
+	renamed := filepath.Join(dir, "renamed.flac")
+	assert.NoError(ioutil.WriteFile(first, []byte("first audio"), 0644))
+	assert.NoError(ioutil.WriteFile(second, []byte("second audio"), 0644))
+	assert.NoError(ioutil.WriteFile(renamed, []byte("first audio"), 0644))
+
+	transcribed := []string{}
+	defer func(old func(context.Context, string, IBMCredentials, IBMOptions) (*IBMResult, error)) {
+		transcribeFile = old
+	}(transcribeFile)
+	transcribeFile = func(ctx context.Context, filePath string, creds IBMCredentials, opts IBMOptions) (*IBMResult, error) {
+		transcribed = append(transcribed, filePath)
+		return newTimestampedResult([]ibmWordTimestamp{{filepath.Base(filePath), 0.0, 1.0}}), nil
+	}
+
+	opts := BatchOptions{Cache: &MemoryStore{}}
+	transcripts, err := TranscribeBatch(context.Background(), []string{first, second}, IBMCredentials{}, opts)
+	assert.NoError(err)
+	assert.Equal([]string{first, second}, transcribed)
+	assert.Equal("first.flac", transcripts[first].Text())
+	assert.Equal("second.flac", transcripts[second].Text())
+
+	// the second pass only reads the cache, also for a copy of a file
+	transcribed = []string{}
+	transcripts, err = TranscribeBatch(context.Background(), []string{first, second, renamed}, IBMCredentials{}, opts)
+	assert.NoError(err)
+	assert.Empty(transcribed)
+	assert.Equal("first.flac", transcripts[first].Text())
+	assert.Equal("second.flac", transcripts[second].Text())
+	assert.Equal("first.flac", transcripts[renamed].Text())
+}
+
+func TestTranscribeBatchContinuesAfterFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(old func(context.Context, string, IBMCredentials, IBMOptions) (*IBMResult, error)) {
+		transcribeFile = old
+	}(transcribeFile)
+	transcribeFile = func(ctx context.Context, filePath string, creds IBMCredentials, opts IBMOptions) (*IBMResult, error) {
+		return newTimestampedResult([]ibmWordTimestamp{{"hello", 0.0, 1.0}}), nil
+	}
+
+	// the missing file cannot be hashed
+	transcripts, err := TranscribeBatch(context.Background(), []string{"missing.flac", "test.flac"}, IBMCredentials{}, BatchOptions{Cache: &MemoryStore{}})
+	assert.Error(err)
+	assert.Len(transcripts, 1)
+	assert.Equal("hello", transcripts["test.flac"].Text())
+}
@@ -0,0 +1,83 @@
+package transcription
+
+import (
+	"context"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// AudioChunk is a piece of a longer audio file, such as one made by
+// SplitWavFile.
+type AudioChunk struct {
+	Path string
+	// Start is the time, in seconds, in the whole audio at which the chunk
+	// starts.
+	Start float64
+}
+
+// ChunkOptions contains optional settings for TranscribeChunks.
+type ChunkOptions struct {
+	// IBMOptions are used to transcribe every chunk.
+	IBMOptions IBMOptions
+	// Concurrency is how many chunks are transcribed at the same time.
+	// Defaults to 1.
+	Concurrency int
+	// OverlapSeconds is how long consecutive chunks overlap, such as the 5
+	// seconds of SplitWavFile. Words of the overlap are only kept once.
+	OverlapSeconds float64
+}
+
+// TranscribeChunks transcribes chunks, up to opts.Concurrency at a time, and
+// stitches their results together in the order of chunks with DedupeOverlap.
+// The timestamps of the result are relative to the whole audio. If a chunk
+// cannot be transcribed, the chunks which have not started are skipped and
+// the error of the earliest failed chunk is returned, in which errors.As finds
+// the *TranscribeError of the chunk.
+func TranscribeChunks(ctx context.Context, chunks []AudioChunk, creds IBMCredentials, opts ChunkOptions) (*IBMResult, error) {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*IBMResult, len(chunks))
+	errs := make([]error, len(chunks))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indices {
+				res, err := transcribeFile(ctx, chunks[index].Path, creds, opts.IBMOptions)
+				if err != nil {
+					errs[index] = annotatePhase(err, "could not transcribe chunk %d", index)
+					cancel()
+					continue
+				}
+				ShiftTimestamps(res, chunks[index].Start)
+				results[index] = res
+			}
+		}()
+	}
+	for index := range chunks {
+		if ctx.Err() != nil {
+			break
+		}
+		indices <- index
+	}
+	close(indices)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return DedupeOverlap(results, opts.OverlapSeconds), nil
+}
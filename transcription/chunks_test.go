@@ -0,0 +1,73 @@
+package transcription
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubChunks replaces transcribeFile with a stub which transcribes each chunk
+// as its path after waiting delays[path], or fails with a *TranscribeError for
+// the path broken. It returns the most chunks which were transcribed at the
+// same time.
+func stubChunks(delays map[string]time.Duration) (maxActive *int32, restore func()) {
+	old := transcribeFile
+	var active int32
+	maxActive = new(int32)
+	transcribeFile = func(ctx context.Context, filePath string, creds IBMCredentials, opts IBMOptions) (*IBMResult, error) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			max := atomic.LoadInt32(maxActive)
+			if n <= max || atomic.CompareAndSwapInt32(maxActive, max, n) {
+				break
+			}
+		}
+		time.Sleep(delays[filePath])
+		if filePath == "broken" {
+			return nil, &TranscribeError{Path: filePath, Err: errors.New("broken chunk")}
+		}
+		return newTimestampedResult([]ibmWordTimestamp{{filePath, 0.0, 1.0}}), nil
+	}
+	return maxActive, func() { transcribeFile = old }
+}
+
+func TestTranscribeChunksKeepsChunkOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	// the chunks finish in reverse order
+	maxActive, restore := stubChunks(map[string]time.Duration{
+		"zero":  40 * time.Millisecond,
+		"one":   30 * time.Millisecond,
+		"two":   20 * time.Millisecond,
+		"three": 10 * time.Millisecond,
+	})
+	defer restore()
+
+	chunks := []AudioChunk{{"zero", 0}, {"one", 10}, {"two", 20}, {"three", 30}}
+	res, err := TranscribeChunks(context.Background(), chunks, IBMCredentials{}, ChunkOptions{Concurrency: 2})
+	assert.NoError(err)
+	assert.Equal([]string{"zero ", "one ", "two ", "three "}, transcripts(res))
+	assert.Equal(30.0, res.Results[3].Alternatives[0].Timestamps[0][1])
+	assert.Equal(int32(2), atomic.LoadInt32(maxActive))
+}
+
+func TestTranscribeChunksReturnsErrorOfChunk(t *testing.T) {
+	assert := assert.New(t)
+
+	_, restore := stubChunks(nil)
+	defer restore()
+
+	chunks := []AudioChunk{{"zero", 0}, {"broken", 10}, {"two", 20}}
+	_, err := TranscribeChunks(context.Background(), chunks, IBMCredentials{}, ChunkOptions{Concurrency: 2})
+	assert.Error(err)
+	assert.Contains(err.Error(), "could not transcribe chunk 1")
+	var transcribeErr *TranscribeError
+	if assert.True(errors.As(err, &transcribeErr)) {
+		assert.Equal("broken", transcribeErr.Path)
+	}
+}
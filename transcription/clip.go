@@ -0,0 +1,27 @@
+package transcription
+
+import (
+	"strconv"
+
+	"github.com/juju/errors"
+)
+
+// ExtractClip writes the audio of the file at filePath between start and end,
+// in seconds, to outPath with ffmpeg, such as to listen to a word found with
+// its timestamps. The format of the clip is chosen by the extension of
+// outPath, and an existing file at outPath is overwritten. Unlike conversion
+// and splitting, it does not use DefaultConverter.
+func ExtractClip(filePath string, start, end float64, outPath string) error {
+	if start < 0 || end <= start {
+		return errors.Errorf("invalid clip from %v to %v seconds", start, end)
+	}
+	// -ss and -to after -i seek precisely in the decoded audio
+	out, err := runFFmpeg("-y", "-i", filePath,
+		"-ss", strconv.FormatFloat(start, 'f', 3, 64),
+		"-to", strconv.FormatFloat(end, 'f', 3, 64),
+		outPath)
+	if err != nil {
+		return errors.New(err.Error() + "\nCommand Output:" + string(out))
+	}
+	return nil
+}
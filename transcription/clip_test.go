@@ -0,0 +1,27 @@
+package transcription
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractClipPassesTimestampsToFFmpeg(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(run func(...string) ([]byte, error)) { runFFmpeg = run }(runFFmpeg)
+	var calls [][]string
+	runFFmpeg = func(args ...string) ([]byte, error) {
+		calls = append(calls, args)
+		return nil, nil
+	}
+
+	assert.NoError(ExtractClip("audio.flac", 1.25, 2.5, "clip.wav"))
+	assert.Equal([][]string{
+		{"-y", "-i", "audio.flac", "-ss", "1.250", "-to", "2.500", "clip.wav"},
+	}, calls)
+
+	assert.Error(ExtractClip("audio.flac", 2.5, 1.25, "clip.wav"))
+	assert.Error(ExtractClip("audio.flac", -1, 1, "clip.wav"))
+	assert.Len(calls, 1)
+}
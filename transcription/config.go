@@ -0,0 +1,152 @@
+package transcription
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strconv"
+
+	"github.com/juju/errors"
+)
+
+// Config contains everything needed to transcribe files and email the
+// transcripts, so that command line tools do not have to thread credentials
+// through every call.
+type Config struct {
+	IBM   IBMCredentials
+	Email EmailConfig
+	// Model is the IBM model used for transcription.
+	Model string
+}
+
+// fileConfig is the JSON representation of a Config. The keys match those of
+// the app's config.toml.
+type fileConfig struct {
+	IBMUsername     string
+	IBMPassword     string
+	IBMURL          string
+	IBMModel        string
+	EmailUsername   string
+	EmailPassword   string
+	EmailSMTPServer string
+	EmailPort       int
+}
+
+// Environment variables read by LoadFromEnv.
+const (
+	envIBMUsername     = "TRANSCRIBE4ALL_IBM_USERNAME"
+	envIBMPassword     = "TRANSCRIBE4ALL_IBM_PASSWORD"
+	envIBMURL          = "TRANSCRIBE4ALL_IBM_URL"
+	envIBMModel        = "TRANSCRIBE4ALL_IBM_MODEL"
+	envEmailUsername   = "TRANSCRIBE4ALL_EMAIL_USERNAME"
+	envEmailPassword   = "TRANSCRIBE4ALL_EMAIL_PASSWORD"
+	envEmailSMTPServer = "TRANSCRIBE4ALL_EMAIL_SMTP_SERVER"
+	envEmailPort       = "TRANSCRIBE4ALL_EMAIL_PORT"
+)
+
+// LoadFromEnv populates c from TRANSCRIBE4ALL_* environment variables, such as
+// TRANSCRIBE4ALL_IBM_USERNAME.
+func (c *Config) LoadFromEnv() error {
+	fc := fileConfig{
+		IBMUsername:     os.Getenv(envIBMUsername),
+		IBMPassword:     os.Getenv(envIBMPassword),
+		IBMURL:          os.Getenv(envIBMURL),
+		IBMModel:        os.Getenv(envIBMModel),
+		EmailUsername:   os.Getenv(envEmailUsername),
+		EmailPassword:   os.Getenv(envEmailPassword),
+		EmailSMTPServer: os.Getenv(envEmailSMTPServer),
+	}
+	if port := os.Getenv(envEmailPort); port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return errors.Annotatef(err, "invalid %s", envEmailPort)
+		}
+		fc.EmailPort = p
+	}
+	return errors.Trace(c.load(fc))
+}
+
+// LoadFromFile populates c from the JSON file at path. The file uses the same
+// keys as config.toml, such as "IBMUsername" and "EmailSMTPServer".
+func (c *Config) LoadFromFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer file.Close()
+
+	var fc fileConfig
+	if err := json.NewDecoder(file).Decode(&fc); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(c.load(fc))
+}
+
+// load populates c from fc, applying defaults and checking required fields.
+func (c *Config) load(fc fileConfig) error {
+	if fc.IBMUsername == "" || fc.IBMPassword == "" {
+		return errors.New("IBM username and password are required")
+	}
+	if fc.EmailUsername != "" && fc.EmailSMTPServer == "" {
+		return errors.New("email SMTP server is required when an email username is set")
+	}
+	if fc.EmailPort == 0 {
+		fc.EmailPort = 587
+	}
+
+	*c = Config{
+		IBM: IBMCredentials{
+			Username: fc.IBMUsername,
+			Password: fc.IBMPassword,
+			URL:      fc.IBMURL,
+		},
+		Email: EmailConfig{
+			Username: fc.EmailUsername,
+			Password: fc.EmailPassword,
+			Host:     fc.EmailSMTPServer,
+			Port:     fc.EmailPort,
+		},
+		Model: fc.IBMModel,
+	}
+	return nil
+}
+
+// options returns the IBMOptions described by c.
+func (c *Config) options() IBMOptions {
+	return IBMOptions{Model: c.Model}
+}
+
+// TranscribeWithIBM transcribes the file at filePath like
+// TranscribeWithIBMContext, with the IBM credentials and model of c.
+func (c *Config) TranscribeWithIBM(ctx context.Context, filePath string, searchWords []string) (*IBMResult, error) {
+	return TranscribeWithIBMContext(ctx, filePath, searchWords, c.IBM, c.options())
+}
+
+// TranscribeBatch transcribes the files at filePaths like the function
+// TranscribeBatch, with the IBM credentials of c. The model of c is used
+// unless opts.IBMOptions has one.
+func (c *Config) TranscribeBatch(ctx context.Context, filePaths []string, opts BatchOptions) (map[string]*Transcript, error) {
+	if opts.IBMOptions.Model == "" {
+		opts.IBMOptions.Model = c.Model
+	}
+	return TranscribeBatch(ctx, filePaths, c.IBM, opts)
+}
+
+// SendEmail sends a plain text email like SendEmailContext through the email
+// server of c.
+func (c *Config) SendEmail(ctx context.Context, to []string, subject string, body string) error {
+	return SendEmailContext(ctx, c.Email.Username, c.Email.Password, c.Email.Host, c.Email.Port, to, subject, body)
+}
+
+// SendDigestEmail sends a digest of results like the function SendDigestEmail
+// through the email server of c.
+func (c *Config) SendDigestEmail(ctx context.Context, results map[string]*IBMResult, to []string, opts DigestOptions) error {
+	return SendDigestEmail(ctx, c.Email, results, to, opts)
+}
+
+// TranscribeAndNotify transcribes the file at filePath and emails the
+// transcript like the function TranscribeAndNotify, with the IBM credentials,
+// model and email server of c.
+func (c *Config) TranscribeAndNotify(ctx context.Context, filePath string, to []string) error {
+	return transcribeAndNotify(ctx, filePath, c.IBM, c.options(), c.Email, to)
+}
@@ -0,0 +1,124 @@
+package transcription
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setEnv sets the environment variables in env and returns a function which
+// unsets them.
+func setEnv(env map[string]string) func() {
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	return func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func TestConfigLoadFromEnv(t *testing.T) {
+	assert := assert.New(t)
+	defer setEnv(map[string]string{
+		"TRANSCRIBE4ALL_IBM_USERNAME":      "user",
+		"TRANSCRIBE4ALL_IBM_PASSWORD":      "pass",
+		"TRANSCRIBE4ALL_IBM_MODEL":         "en-US_NarrowbandModel",
+		"TRANSCRIBE4ALL_EMAIL_USERNAME":    "from@email.com",
+		"TRANSCRIBE4ALL_EMAIL_SMTP_SERVER": "smtp.gmail.com",
+		"TRANSCRIBE4ALL_EMAIL_PORT":        "25",
+	})()
+
+	var cfg Config
+	assert.NoError(cfg.LoadFromEnv())
+	assert.Equal(Config{
+		IBM:   IBMCredentials{Username: "user", Password: "pass"},
+		Email: EmailConfig{Username: "from@email.com", Host: "smtp.gmail.com", Port: 25},
+		Model: "en-US_NarrowbandModel",
+	}, cfg)
+}
+
+func TestConfigLoadFromEnvRequiresIBMCredentials(t *testing.T) {
+	assert := assert.New(t)
+	defer setEnv(map[string]string{"TRANSCRIBE4ALL_IBM_USERNAME": "user"})()
+
+	var cfg Config
+	assert.Error(cfg.LoadFromEnv())
+}
+
+func TestConfigLoadFromFile(t *testing.T) {
+	assert := assert.New(t)
+
+	path := writeTempFile(t, []byte(`{
+		"IBMUsername": "user",
+		"IBMPassword": "pass",
+		"EmailUsername": "from@email.com",
+		"EmailSMTPServer": "smtp.gmail.com"
+	}`))
+	defer os.Remove(path)
+
+	var cfg Config
+	assert.NoError(cfg.LoadFromFile(path))
+	assert.Equal(Config{
+		IBM:   IBMCredentials{Username: "user", Password: "pass"},
+		Email: EmailConfig{Username: "from@email.com", Host: "smtp.gmail.com", Port: 587},
+	}, cfg)
+}
+
+func TestConfigLoadFromFileRequiresSMTPServer(t *testing.T) {
+	assert := assert.New(t)
+
+	path := writeTempFile(t, []byte(`{"IBMUsername": "user", "IBMPassword": "pass", "EmailUsername": "from@email.com"}`))
+	defer os.Remove(path)
+
+	var cfg Config
+	assert.Error(cfg.LoadFromFile(path))
+}
+
+func TestConfigTranscribesWithItsSettings(t *testing.T) {
+	assert := assert.New(t)
+
+	server, requests := newRecordingIBMServer(t)
+	defer server.Close()
+	cfg := Config{
+		IBM:   IBMCredentials{Username: "user", Password: "pass", URL: server.URL},
+		Model: "en-GB_BroadbandModel",
+	}
+
+	res, err := cfg.TranscribeWithIBM(context.Background(), "test.flac", nil)
+	assert.NoError(err)
+	assert.Equal([]string{"hello "}, transcripts(res))
+	request := <-requests
+	assert.Equal("en-GB_BroadbandModel", request.Query.Get("model"))
+	assert.Equal("Basic "+basicAuth("user", "pass"), request.Header.Get("Authorization"))
+
+	defer func(old func(context.Context, string, IBMCredentials, IBMOptions) (*IBMResult, error)) {
+		transcribeFile = old
+	}(transcribeFile)
+	transcribeFile = func(ctx context.Context, filePath string, creds IBMCredentials, opts IBMOptions) (*IBMResult, error) {
+		assert.Equal(cfg.IBM, creds)
+		assert.Equal("en-GB_BroadbandModel", opts.Model)
+		return newTimestampedResult([]ibmWordTimestamp{{"hello", 0.0, 1.0}}), nil
+	}
+	transcripts, err := cfg.TranscribeBatch(context.Background(), []string{"test.flac"}, BatchOptions{})
+	assert.NoError(err)
+	assert.Len(transcripts, 1)
+}
+
+func TestConfigSendsEmailWithItsSettings(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newMockSMTPServer(t)
+	defer server.Close()
+	cfg := Config{Email: server.config()}
+
+	assert.NoError(cfg.SendEmail(context.Background(), []string{"to@email.com"}, "subject", "body"))
+	results := map[string]*IBMResult{"audio.flac": newTranscriptResult(0, true, "hello world ")}
+	assert.NoError(cfg.SendDigestEmail(context.Background(), results, []string{"to@email.com"}, DigestOptions{}))
+	server.Lock()
+	defer server.Unlock()
+	assert.Len(server.messages, 2)
+}
@@ -0,0 +1,72 @@
+package transcription
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/juju/errors"
+)
+
+// Converter converts audio files between formats.
+type Converter interface {
+	// Convert converts the audio file at src into dstFormat, such as flac, and
+	// returns the path of the converted file.
+	Convert(src, dstFormat string) (string, error)
+}
+
+// SegmentExtractor is implemented by a Converter which can also cut a piece
+// out of an audio file, which SplitWavFile uses.
+type SegmentExtractor interface {
+	// ExtractSegment writes the audio of the file at src from start, in
+	// seconds, for duration seconds to dst.
+	ExtractSegment(src, dst string, start, duration int) error
+}
+
+// DefaultConverter is the Converter used by ConvertAudioIntoFormat and
+// SplitWavFile, and so by the tasks made by MakeIBMTaskFunction. Set it to use
+// a different conversion command or encoder. SplitWavFile uses its
+// ExtractSegment if it is a SegmentExtractor, and ffmpeg otherwise.
+var DefaultConverter Converter = FFmpegConverter{}
+
+// runFFmpeg runs ffmpeg with args and returns its combined output. It is a
+// variable so that tests can stub it.
+var runFFmpeg = func(args ...string) ([]byte, error) {
+	return exec.Command("ffmpeg", args...).CombinedOutput()
+}
+
+// FFmpegConverter converts audio with the ffmpeg command into 16kHz mono
+// audio. The converted file is written next to the original, with the new
+// format appended to its name.
+type FFmpegConverter struct{}
+
+// Convert implements Converter.
+func (FFmpegConverter) Convert(src, dstFormat string) (string, error) {
+	// http://cmusphinx.sourceforge.net/wiki/faq
+	// -ar 16000 sets frequency to required 16khz
+	// -ac 1 sets the number of audio channels to 1
+	newPath := src + "." + dstFormat
+	os.Remove(newPath) // If it already exists, ffmpeg will throw an error
+	if out, err := runFFmpeg("-i", src, "-ar", "16000", "-ac", "1", newPath); err != nil {
+		return "", errors.New(err.Error() + "\nCommand Output:" + string(out))
+	}
+	return newPath, nil
+}
+
+// ExtractSegment implements SegmentExtractor.
+func (FFmpegConverter) ExtractSegment(src, dst string, start, duration int) error {
+	// -ss: starting second, -t: duration in seconds
+	if out, err := runFFmpeg("-i", src, "-ss", strconv.Itoa(start), "-t", strconv.Itoa(duration), dst); err != nil {
+		return errors.New(err.Error() + "\nOutput:\n" + string(out))
+	}
+	return nil
+}
+
+// segmentExtractor returns the SegmentExtractor of DefaultConverter, or an
+// FFmpegConverter if it has none.
+func segmentExtractor() SegmentExtractor {
+	if extractor, ok := DefaultConverter.(SegmentExtractor); ok {
+		return extractor
+	}
+	return FFmpegConverter{}
+}
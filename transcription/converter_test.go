@@ -0,0 +1,85 @@
+package transcription
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeConverter records its conversions instead of converting.
+type fakeConverter struct {
+	calls [][2]string
+}
+
+// fakeSplitter is a fakeConverter which also records the segments it is asked
+// to extract.
+type fakeSplitter struct {
+	fakeConverter
+	segments [][2]int
+}
+
+func (c *fakeSplitter) ExtractSegment(src, dst string, start, duration int) error {
+	c.segments = append(c.segments, [2]int{start, duration})
+	return nil
+}
+
+func (c *fakeConverter) Convert(src, dstFormat string) (string, error) {
+	c.calls = append(c.calls, [2]string{src, dstFormat})
+	return "converted." + dstFormat, nil
+}
+
+func TestConvertAudioIntoFormatUsesDefaultConverter(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(converter Converter) { DefaultConverter = converter }(DefaultConverter)
+	converter := new(fakeConverter)
+	DefaultConverter = converter
+
+	path, err := ConvertAudioIntoFormat("audio.mp3", "flac")
+	assert.NoError(err)
+	assert.Equal("converted.flac", path)
+	assert.Equal([][2]string{{"audio.mp3", "flac"}}, converter.calls)
+}
+
+func TestSplitWavFileUsesDefaultConverter(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(converter Converter) { DefaultConverter = converter }(DefaultConverter)
+	converter := new(fakeSplitter)
+	DefaultConverter = converter
+	defer func(run func(...string) ([]byte, error)) { runFFmpeg = run }(runFFmpeg)
+	runFFmpeg = func(args ...string) ([]byte, error) {
+		t.Errorf("ffmpeg was run with %v", args)
+		return nil, nil
+	}
+
+	// a sparse file is large enough to be split into two chunks
+	const wavPath = "split_test.wav"
+	file, err := os.Create(wavPath)
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.Remove(wavPath)
+	assert.NoError(file.Truncate(100000000))
+	file.Close()
+
+	paths, err := SplitWavFile(wavPath)
+	assert.NoError(err)
+	assert.Equal([]string{"0_split_test.wav", "1_split_test.wav"}, paths)
+	assert.Equal([][2]int{{0, 2968}, {2963, 2968}}, converter.segments)
+}
+
+func TestFFmpegConverterExtractsSegment(t *testing.T) {
+	assert := assert.New(t)
+
+	var ran []string
+	defer func(run func(...string) ([]byte, error)) { runFFmpeg = run }(runFFmpeg)
+	runFFmpeg = func(args ...string) ([]byte, error) {
+		ran = args
+		return nil, nil
+	}
+
+	assert.NoError(FFmpegConverter{}.ExtractSegment("in.wav", "out.wav", 5, 10))
+	assert.Equal([]string{"-i", "in.wav", "-ss", "5", "-t", "10", "out.wav"}, ran)
+}
@@ -0,0 +1,47 @@
+package transcription
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// TranscribeDataURL transcribes audio given inline as a base64 data URL, such
+// as data:audio/flac;base64,ZkxhQw... The audio is decoded as it is streamed
+// to IBM, with the MIME type of the data URL as its content type. It returns
+// a *TranscribeError if transcription fails.
+func TranscribeDataURL(ctx context.Context, dataURL string, creds IBMCredentials) (*IBMResult, error) {
+	contentType, audio, err := parseDataURL(dataURL)
+	if err != nil {
+		return nil, &TranscribeError{Err: errors.Trace(err)}
+	}
+	res, err := TranscribeReaderWithIBM(ctx, audio, nil, creds, IBMOptions{ContentType: contentType})
+	if err != nil {
+		return nil, tracePhase(err)
+	}
+	return res, nil
+}
+
+// parseDataURL returns the MIME type of a base64 audio data URL, including any
+// parameters such as rate, and a reader of the decoded audio.
+func parseDataURL(dataURL string) (string, io.Reader, error) {
+	if !strings.HasPrefix(dataURL, "data:") {
+		return "", nil, errors.New("not a data url")
+	}
+	comma := strings.Index(dataURL, ",")
+	if comma < 0 {
+		return "", nil, errors.New("data url has no data")
+	}
+	header, data := dataURL[len("data:"):comma], dataURL[comma+1:]
+	if !strings.HasSuffix(header, ";base64") {
+		return "", nil, errors.New("data url is not base64 encoded")
+	}
+	contentType := strings.TrimSuffix(header, ";base64")
+	if !strings.HasPrefix(contentType, "audio/") {
+		return "", nil, errors.Errorf("data url has type %q, not audio", contentType)
+	}
+	return contentType, base64.NewDecoder(base64.StdEncoding, strings.NewReader(data)), nil
+}
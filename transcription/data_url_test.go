@@ -0,0 +1,50 @@
+package transcription
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranscribeDataURL(t *testing.T) {
+	assert := assert.New(t)
+
+	server, requests := newRecordingIBMServer(t)
+	defer server.Close()
+
+	audio, err := ioutil.ReadFile("test.flac")
+	assert.NoError(err)
+	dataURL := "data:audio/flac;base64," + base64.StdEncoding.EncodeToString(audio)
+
+	creds := IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	res, err := TranscribeDataURL(context.Background(), dataURL, creds)
+	assert.NoError(err)
+	if assert.NotNil(res) {
+		assert.Equal([]string{"hello "}, transcripts(res))
+	}
+
+	request := <-requests
+	assert.Equal("audio/flac", request.Start["content-type"])
+	assert.Equal(audio, request.Audio)
+}
+
+func TestParseDataURLRejectsInvalidURLs(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, dataURL := range []string{
+		"data:text/plain;base64,aGVsbG8=",
+		"data:audio/flac,fLaC",
+		"data:audio/flac;base64",
+		"https://example.com/audio.flac",
+	} {
+		_, _, err := parseDataURL(dataURL)
+		assert.Error(err, dataURL)
+	}
+
+	contentType, _, err := parseDataURL("data:audio/l16;rate=16000;base64,AAAA")
+	assert.NoError(err)
+	assert.Equal("audio/l16;rate=16000", contentType)
+}
@@ -0,0 +1,103 @@
+package transcription
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DedupeOverlap stitches together the results of transcribing consecutive
+// chunks of audio which overlap by overlapSeconds. The timestamps of results
+// must already be relative to the whole audio, see ShiftTimestamps. A word at
+// the start of a chunk which matches a word of the previous chunk's overlap,
+// both in text and in time, is removed, so each word of the overlap appears
+// once.
+func DedupeOverlap(results []*IBMResult, overlapSeconds float64) *IBMResult {
+	merged := &IBMResult{}
+	if len(results) > 0 {
+		merged.Model = results[0].Model
+	}
+	previous := []timestamp{}
+	for _, res := range results {
+		previousEnd := 0.0
+		overlap := []timestamp{}
+		if len(previous) > 0 {
+			previousEnd = previous[len(previous)-1].EndTime
+			for _, word := range previous {
+				if word.StartTime >= previousEnd-overlapSeconds {
+					overlap = append(overlap, word)
+				}
+			}
+		}
+
+		// each word of the overlap removes at most one duplicate
+		matched := make([]bool, len(overlap))
+		isDuplicate := func(word timestamp) bool {
+			for i, other := range overlap {
+				if !matched[i] && sameWord(word, other) {
+					matched[i] = true
+					return true
+				}
+			}
+			return false
+		}
+		for _, subResult := range res.Results {
+			if deduped, ok := withoutWords(subResult, isDuplicate); ok {
+				merged.Results = append(merged.Results, deduped)
+			}
+		}
+		for _, label := range res.SpeakerLabels {
+			if label.From >= previousEnd {
+				merged.SpeakerLabels = append(merged.SpeakerLabels, label)
+			}
+		}
+		previous = res.words()
+	}
+	return merged
+}
+
+// sameWord returns whether a and b are the same word spoken at the same time.
+func sameWord(a, b timestamp) bool {
+	return normalizeWord(a.Word) == normalizeWord(b.Word) &&
+		a.StartTime < b.EndTime && b.StartTime < a.EndTime
+}
+
+// normalizeWord returns word in lowercase without surrounding punctuation.
+func normalizeWord(word string) string {
+	return strings.ToLower(strings.TrimFunc(word, unicode.IsPunct))
+}
+
+// withoutWords returns result without the words of its best hypothesis for
+// which remove returns true. The other hypotheses are dropped if any word is
+// removed. It returns false if no words remain.
+func withoutWords(result ibmResultField, remove func(timestamp) bool) (ibmResultField, bool) {
+	if len(result.Alternatives) == 0 {
+		return result, true
+	}
+	best := result.Alternatives[0]
+	kept := ibmAlternativesField{OverallConfidence: best.OverallConfidence}
+	words := []string{}
+	for i, ibmTimestamp := range best.Timestamps {
+		word := timestamp{
+			Word:      ibmTimestamp[0].(string),
+			StartTime: ibmTimestamp[1].(float64),
+			EndTime:   ibmTimestamp[2].(float64),
+		}
+		if remove(word) {
+			continue
+		}
+		kept.Timestamps = append(kept.Timestamps, ibmTimestamp)
+		if i < len(best.WordConfidence) {
+			kept.WordConfidence = append(kept.WordConfidence, best.WordConfidence[i])
+		}
+		words = append(words, word.Word)
+	}
+	if len(kept.Timestamps) == len(best.Timestamps) {
+		return result, true
+	}
+	if len(words) == 0 {
+		return result, false
+	}
+	kept.Transcript = strings.Join(words, " ") + " "
+	result.Alternatives = []ibmAlternativesField{kept}
+	return result, true
+}
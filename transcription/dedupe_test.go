@@ -0,0 +1,38 @@
+package transcription
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupeOverlap(t *testing.T) {
+	assert := assert.New(t)
+
+	first := newTimestampedResult(
+		[]ibmWordTimestamp{{"the", 0.0, 1.0}, {"quick", 1.0, 2.0}},
+		[]ibmWordTimestamp{{"brown", 2.0, 3.0}, {"fox", 3.0, 4.0}},
+	)
+	// the second chunk starts at 2 seconds and hears the end of the first again
+	second := newTimestampedResult(
+		[]ibmWordTimestamp{{"Brown", 2.1, 3.0}, {"fox.", 3.0, 3.9}, {"jumps", 4.0, 5.0}},
+		[]ibmWordTimestamp{{"the", 5.0, 6.0}, {"fox", 6.0, 7.0}},
+	)
+
+	merged := DedupeOverlap([]*IBMResult{first, second}, 2)
+	assert.Equal([]string{"the quick ", "brown fox ", "jumps ", "the fox "}, transcripts(merged))
+	assert.Equal("the quick brown fox jumps the fox", GetTranscription([]*IBMResult{merged}).Transcript)
+}
+
+func TestDedupeOverlapDropsEmptyResults(t *testing.T) {
+	assert := assert.New(t)
+
+	first := newTimestampedResult([]ibmWordTimestamp{{"hello", 0.0, 1.0}})
+	second := newTimestampedResult(
+		[]ibmWordTimestamp{{"hello", 0.1, 1.0}},
+		[]ibmWordTimestamp{{"world", 1.0, 2.0}},
+	)
+
+	merged := DedupeOverlap([]*IBMResult{first, second}, 1)
+	assert.Equal([]string{"hello ", "world "}, transcripts(merged))
+}
@@ -0,0 +1,81 @@
+package transcription
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// digestSnippetWords is how many words of each transcript are in a digest.
+const digestSnippetWords = 30
+
+// DigestOptions contains optional settings for SendDigestEmail.
+type DigestOptions struct {
+	// Links maps the name of a file to a url where its transcript can be
+	// found, such as in the web interface, which is listed with it.
+	Links map[string]string
+	// AttachSRT attaches the subtitles of every file, named after the file.
+	AttachSRT bool
+}
+
+// SendDigestEmail sends a single email about a batch of transcriptions, rather
+// than one per file. results maps the name of each file to its result. The
+// email lists each file in order of name with the start of its transcript and
+// its link, if it has one. It returns an *EmailError if sending fails.
+func SendDigestEmail(ctx context.Context, cfg EmailConfig, results map[string]*IBMResult, to []string, opts DigestOptions) error {
+	subject := fmt.Sprintf("Transcription digest of %d files", len(results))
+	body := digestBody(results, opts)
+	if !opts.AttachSRT {
+		return SendEmailWithAttachments(ctx, cfg, to, subject, body, nil)
+	}
+
+	dir, err := ioutil.TempDir("", "digest")
+	if err != nil {
+		return &EmailError{To: to, Err: errors.Trace(err)}
+	}
+	defer os.RemoveAll(dir)
+	attachments := []string{}
+	for _, name := range sortedResultNames(results) {
+		path := filepath.Join(dir, strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))+".srt")
+		if err := ioutil.WriteFile(path, []byte(results[name].ToSRT()), 0644); err != nil {
+			return &EmailError{To: to, Err: errors.Trace(err)}
+		}
+		attachments = append(attachments, path)
+	}
+	return SendEmailWithAttachments(ctx, cfg, to, subject, body, attachments)
+}
+
+// digestBody returns the body of the email of SendDigestEmail.
+func digestBody(results map[string]*IBMResult, opts DigestOptions) string {
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "%d files were transcribed.\n", len(results))
+	for _, name := range sortedResultNames(results) {
+		words := strings.Fields(results[name].ToTranscript().Text())
+		snippet := strings.Join(words, " ")
+		if len(words) > digestSnippetWords {
+			snippet = strings.Join(words[:digestSnippetWords], " ") + " ..."
+		}
+		fmt.Fprintf(&body, "\n%s\n%s\n", name, snippet)
+		if link, ok := opts.Links[name]; ok {
+			fmt.Fprintf(&body, "%s\n", link)
+		}
+	}
+	return body.String()
+}
+
+// sortedResultNames returns the names of results in order.
+func sortedResultNames(results map[string]*IBMResult) []string {
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
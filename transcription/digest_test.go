@@ -0,0 +1,51 @@
+package transcription
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigestBodyMentionsEveryFile(t *testing.T) {
+	assert := assert.New(t)
+
+	long := []ibmWordTimestamp{}
+	for i := 0; i < 40; i++ {
+		long = append(long, ibmWordTimestamp{"word", float64(i), float64(i) + 0.5})
+	}
+	results := map[string]*IBMResult{
+		"second.flac": newTimestampedResult([]ibmWordTimestamp{{"goodbye", 0.0, 1.0}}),
+		"first.flac":  newTimestampedResult([]ibmWordTimestamp{{"hello", 0.0, 0.5}, {"there", 0.5, 1.0}}),
+		"long.flac":   newTimestampedResult(long),
+	}
+	links := map[string]string{"first.flac": "https://example.com/first"}
+
+	body := digestBody(results, DigestOptions{Links: links})
+	assert.Contains(body, "3 files were transcribed.")
+	assert.Contains(body, "\nfirst.flac\nhello there\nhttps://example.com/first\n")
+	assert.Contains(body, "\nsecond.flac\ngoodbye\n")
+	assert.Contains(body, strings.Repeat("word ", 30)+"...")
+	assert.True(strings.Index(body, "first.flac") < strings.Index(body, "second.flac"))
+}
+
+func TestSendDigestEmailAttachesSRT(t *testing.T) {
+	assert := assert.New(t)
+	server := newMockSMTPServer(t)
+	defer server.Close()
+
+	results := map[string]*IBMResult{
+		"first.flac":  newTimestampedResult([]ibmWordTimestamp{{"hello", 0.0, 0.5}}),
+		"second.flac": newTimestampedResult([]ibmWordTimestamp{{"goodbye", 0.0, 1.0}}),
+	}
+	err := SendDigestEmail(context.Background(), server.config(), results, []string{"to@email.com"}, DigestOptions{AttachSRT: true})
+	assert.NoError(err)
+
+	server.Lock()
+	defer server.Unlock()
+	if assert.Len(server.messages, 1) {
+		assert.Contains(server.messages[0], "first.srt")
+		assert.Contains(server.messages[0], "second.srt")
+	}
+}
@@ -0,0 +1,88 @@
+package transcription
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+
+	"github.com/juju/errors"
+)
+
+// The parts of a minimal Office Open XML document besides word/document.xml.
+const (
+	docxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+</Types>`
+	docxRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>
+</Relationships>`
+)
+
+// ToDocx writes the transcript to a Word document at path. The transcript is
+// split into paragraphs like IBMResult.ToParagraphs, and each paragraph with
+// a known speaker starts with a bold label, such as "Speaker 1:". Speakers
+// are numbered from 1.
+func (t *Transcript) ToDocx(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	parts := []struct {
+		name    string
+		content []byte
+	}{
+		{"[Content_Types].xml", []byte(docxContentTypes)},
+		{"_rels/.rels", []byte(docxRelationships)},
+		{"word/document.xml", t.docxDocument()},
+	}
+	for _, part := range parts {
+		w, err := archive.Create(part.name)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if _, err := w.Write(part.content); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(file.Close())
+}
+
+// docxDocument returns the word/document.xml part with the paragraphs of the
+// transcript.
+func (t *Transcript) docxDocument() []byte {
+	var document bytes.Buffer
+	document.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	document.WriteString(`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>`)
+	for _, p := range t.paragraphs() {
+		document.WriteString("<w:p>")
+		if p.Speaker >= 0 {
+			document.WriteString(`<w:r><w:rPr><w:b/></w:rPr>`)
+			writeDocxText(&document, fmt.Sprintf("Speaker %d: ", p.Speaker+1))
+			document.WriteString("</w:r>")
+		}
+		document.WriteString("<w:r>")
+		writeDocxText(&document, p.Text)
+		document.WriteString("</w:r></w:p>")
+	}
+	document.WriteString("</w:body></w:document>")
+	return document.Bytes()
+}
+
+// writeDocxText writes a text element with text, keeping its spaces.
+func writeDocxText(document *bytes.Buffer, text string) {
+	document.WriteString(`<w:t xml:space="preserve">`)
+	xml.EscapeText(document, []byte(text))
+	document.WriteString("</w:t>")
+}
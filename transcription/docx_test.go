@@ -0,0 +1,68 @@
+package transcription
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToDocx(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{{"hello", 0.5, 1.0}, {"everyone", 1.0, 1.6}},
+		[]ibmWordTimestamp{{"fish", 2.0, 2.4}, {"&", 2.4, 2.6}, {"chips", 2.6, 3.0}},
+	)
+	res.SpeakerLabels = []ibmSpeakerLabel{
+		{From: 0.5, To: 1.0, Speaker: 0},
+		{From: 1.0, To: 1.6, Speaker: 0},
+		{From: 2.0, To: 2.4, Speaker: 1},
+		{From: 2.4, To: 2.6, Speaker: 1},
+		{From: 2.6, To: 3.0, Speaker: 1},
+	}
+
+	dir, err := ioutil.TempDir("", "docx")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "transcript.docx")
+	assert.NoError(res.ToTranscript().ToDocx(path))
+
+	archive, err := zip.OpenReader(path)
+	assert.NoError(err)
+	defer archive.Close()
+	parts := map[string]string{}
+	for _, file := range archive.File {
+		r, err := file.Open()
+		assert.NoError(err)
+		content, err := ioutil.ReadAll(r)
+		assert.NoError(err)
+		r.Close()
+		parts[file.Name] = string(content)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels"} {
+		_, ok := parts[name]
+		assert.True(ok, name)
+	}
+
+	document := parts["word/document.xml"]
+	assert.Contains(document, "<w:b/></w:rPr><w:t xml:space=\"preserve\">Speaker 1: </w:t>")
+	assert.Contains(document, ">Hello everyone<")
+	assert.Contains(document, "Speaker 2: ")
+	assert.Contains(document, ">Fish &amp; chips<")
+
+	// the document is well-formed XML
+	decoder := xml.NewDecoder(strings.NewReader(document))
+	for {
+		if _, err := decoder.Token(); err != nil {
+			assert.Equal(io.EOF, err)
+			break
+		}
+	}
+}
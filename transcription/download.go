@@ -0,0 +1,404 @@
+package transcription
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/juju/errors"
+)
+
+// DownloadOptions contains optional settings for downloading a file.
+type DownloadOptions struct {
+	// Username and Password are used for HTTP basic auth if Username is set.
+	// The credentials are only forwarded on redirects to the same host.
+	Username string
+	Password string
+	// ExpectAudio rejects downloads which are clearly not audio, such as HTML
+	// error pages, based on their Content-Type and first bytes.
+	ExpectAudio bool
+	// Retries is how many times DownloadFileWithRetry retries a failed
+	// download. Defaults to 3.
+	Retries int
+	// SkipIfExists skips the download if dest already exists and has the size
+	// of the Content-Length the server responds with to a HEAD request. The
+	// file is downloaded again if the sizes differ or the server does not say.
+	SkipIfExists bool
+	// InsecureSkipVerify accepts any TLS certificate of the server, such as a
+	// self-signed one of a test server. It is unsafe and must only be used
+	// for testing.
+	InsecureSkipVerify bool
+	// OnRetry is called by DownloadFileWithRetry with the number of the
+	// failed attempt, starting at 1, and its error before every retry, such
+	// as to count retries in metrics.
+	OnRetry func(attempt int, err error)
+	// OnSuccess is called by DownloadFileWithRetry with the number of
+	// attempts, including retries, once the download succeeds.
+	OnSuccess func(attempts int)
+}
+
+// client returns the client which downloads the file.
+func (opts DownloadOptions) client() *http.Client {
+	if opts.InsecureSkipVerify {
+		return insecureHTTPClient
+	}
+	return httpClient
+}
+
+func (opts DownloadOptions) retries() int {
+	if opts.Retries > 0 {
+		return opts.Retries
+	}
+	return 3
+}
+
+// DownloadFileFromURL locally downloads an audio file stored at url. Like all
+// download functions, it returns a *DownloadError if it fails.
+func DownloadFileFromURL(url string) (string, error) {
+	filePath := filePathFromURL(url)
+	if err := DownloadFileWithOptions(url, filePath, DownloadOptions{}); err != nil {
+		return "", tracePhase(err)
+	}
+	return filePath, nil
+}
+
+// DownloadFileWithAuth downloads the file stored at url to dest, using HTTP
+// basic auth with the given username and password. The credentials are only
+// forwarded on redirects to the same host.
+func DownloadFileWithAuth(url, dest, username, password string) error {
+	return tracePhase(DownloadFileWithOptions(url, dest, DownloadOptions{
+		Username: username,
+		Password: password,
+	}))
+}
+
+// DownloadFileWithOptions downloads the file stored at url to dest.
+func DownloadFileWithOptions(url, dest string, opts DownloadOptions) error {
+	if opts.SkipIfExists && isDownloaded(url, dest, opts) {
+		return nil
+	}
+	if _, _, err := download(context.Background(), url, dest, 0, opts); err != nil {
+		return &DownloadError{URL: url, Err: errors.Trace(err)}
+	}
+	return nil
+}
+
+var (
+	// downloadRetryDelay is how long DownloadFileWithRetry waits before
+	// retrying for the first time. The delay doubles after every failed
+	// attempt.
+	downloadRetryDelay = time.Second
+	// downloadMaxRetryDelay is the longest DownloadFileWithRetry waits before
+	// a retry, whatever the Retry-After header of the server says.
+	downloadMaxRetryDelay = 2 * time.Minute
+	// downloadSleep waits delay before a retry, or returns ctx.Err() if ctx
+	// is done first. It is a variable so that tests can stub it.
+	downloadSleep = func(ctx context.Context, delay time.Duration) error {
+		select {
+		case <-time.After(delay):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+)
+
+// DownloadFileWithRetry downloads the file stored at url to dest like
+// DownloadFileWithOptions, but retries if the connection fails or the server
+// has an error. A retry continues after the bytes which were already written,
+// using a Range request, unless the server does not support it. If the
+// server responds with a Retry-After header, such as with 429 Too Many
+// Requests, the retry waits as long as it says instead, but no longer than
+// downloadMaxRetryDelay.
+func DownloadFileWithRetry(url, dest string, opts DownloadOptions) error {
+	return DownloadFileWithRetryContext(context.Background(), url, dest, opts)
+}
+
+// DownloadFileWithRetryContext works like DownloadFileWithRetry, but gives up
+// once ctx is done, whether it is downloading or waiting to retry.
+func DownloadFileWithRetryContext(ctx context.Context, url, dest string, opts DownloadOptions) error {
+	if opts.SkipIfExists && isDownloaded(url, dest, opts) {
+		return nil
+	}
+	var written int64
+	for attempt := 0; ; attempt++ {
+		n, resumable, err := download(ctx, url, dest, written, opts)
+		if err == nil {
+			if opts.OnSuccess != nil {
+				opts.OnSuccess(attempt + 1)
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return &DownloadError{URL: url, Err: errors.Trace(ctx.Err())}
+		}
+		if !isRetryableDownloadError(err) || attempt == opts.retries() {
+			return &DownloadError{URL: url, Err: errors.Trace(err)}
+		}
+		written = 0
+		if resumable {
+			written = n
+		}
+		delay := downloadRetryDelay << uint(attempt)
+		if statusErr, ok := errors.Cause(err).(*downloadStatusError); ok && statusErr.RetryAfter > 0 {
+			delay = statusErr.RetryAfter
+		}
+		if delay > downloadMaxRetryDelay {
+			delay = downloadMaxRetryDelay
+		}
+		log.Warnf("Downloading %s failed, retrying from byte %d in %v: %v", url, written, delay, err)
+		if opts.OnRetry != nil {
+			opts.OnRetry(attempt+1, err)
+		}
+		if err := downloadSleep(ctx, delay); err != nil {
+			return &DownloadError{URL: url, Err: errors.Trace(err)}
+		}
+	}
+}
+
+// downloadStatusError is returned when the server responds to a download with
+// an unsuccessful status.
+type downloadStatusError struct {
+	URL        string
+	Status     string
+	StatusCode int
+	// RetryAfter is how long the server asked to wait before trying again, or
+	// 0 if it did not say.
+	RetryAfter time.Duration
+}
+
+func (e *downloadStatusError) Error() string {
+	return "downloading " + e.URL + " failed with status " + e.Status
+}
+
+// parseRetryAfter returns the delay given by a Retry-After header at time
+// now, which is either a number of seconds or an HTTP date. It returns 0 if
+// the header is missing, invalid or in the past.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	date, err := http.ParseTime(value)
+	if err != nil || !date.After(now) {
+		return 0
+	}
+	return date.Sub(now)
+}
+
+// errNotAudio is the cause of errors for downloads which are not audio.
+var errNotAudio = errors.New("not audio")
+
+// isRetryableDownloadError returns whether a download which failed with err
+// may succeed if it is tried again.
+func isRetryableDownloadError(err error) bool {
+	cause := errors.Cause(err)
+	if statusErr, ok := cause.(*downloadStatusError); ok {
+		code := statusErr.StatusCode
+		return code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+	}
+	return cause != errNotAudio
+}
+
+// isDownloaded returns whether dest already has the size of the file stored
+// at url, according to the Content-Length of a HEAD request.
+func isDownloaded(url, dest string, opts DownloadOptions) bool {
+	info, err := os.Stat(dest)
+	if err != nil {
+		return false
+	}
+	request, err := http.NewRequest("HEAD", url, nil)
+	if err != nil {
+		return false
+	}
+	request.Header.Set("User-Agent", UserAgent)
+	if opts.Username != "" {
+		request.SetBasicAuth(opts.Username, opts.Password)
+	}
+	// the size of an encoded body does not match the file
+	request.Header.Set("Accept-Encoding", "identity")
+	response, err := opts.client().Do(request)
+	if err != nil {
+		log.Debugf("Downloading %s again since HEAD failed: %v", url, err)
+		return false
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 || response.ContentLength < 0 {
+		return false
+	}
+	if response.ContentLength != info.Size() {
+		log.Debugf("Downloading %s again since %s has %d bytes instead of %d", url, dest, info.Size(), response.ContentLength)
+		return false
+	}
+	log.Debugf("Skipping download of %s since %s exists", url, dest)
+	return true
+}
+
+// download downloads the file stored at url to dest. If offset is positive,
+// the first offset bytes of dest are kept and only the rest of the file is
+// requested. It returns the number of bytes in dest and whether a later
+// download can continue after them, which is not the case if the body was
+// encoded.
+func download(ctx context.Context, url, dest string, offset int64, opts DownloadOptions) (int64, bool, error) {
+	// Taken from https://github.com/thbar/golang-playground/blob/master/download-files.go
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return offset, false, errors.Trace(err)
+	}
+	request.Header.Set("User-Agent", UserAgent)
+	if opts.Username != "" {
+		request.SetBasicAuth(opts.Username, opts.Password)
+	}
+	if offset > 0 {
+		// byte offsets of the decoded body do not apply to an encoded one
+		request.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+		request.Header.Set("Accept-Encoding", "identity")
+	} else {
+		// Setting Accept-Encoding disables the transparent decompression of the
+		// default transport, so the body is decoded by decodeBody instead.
+		request.Header.Set("Accept-Encoding", "gzip, deflate")
+	}
+
+	// Get file contents
+	response, err := opts.client().Do(request)
+	if err != nil {
+		return offset, offset > 0, errors.Trace(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return offset, offset > 0, &downloadStatusError{
+			URL:        url,
+			Status:     response.Status,
+			StatusCode: response.StatusCode,
+			RetryAfter: parseRetryAfter(response.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+	if response.StatusCode != http.StatusPartialContent ||
+		!strings.HasPrefix(response.Header.Get("Content-Range"), "bytes "+strconv.FormatInt(offset, 10)+"-") {
+		// the server sent the whole file
+		offset = 0
+	}
+	resumable := response.Header.Get("Content-Encoding") == ""
+
+	decoded, err := decodeBody(response)
+	if err != nil {
+		return offset, false, errors.Trace(err)
+	}
+	defer decoded.Close()
+
+	body := bufio.NewReader(decoded)
+	if opts.ExpectAudio && offset == 0 {
+		if err := checkAudio(response.Header.Get("Content-Type"), body); err != nil {
+			return 0, false, errors.Annotatef(err, "downloading %s failed", url)
+		}
+	}
+
+	file, err := openAt(dest, offset)
+	if err != nil {
+		return offset, false, errors.Trace(err)
+	}
+	defer file.Close()
+
+	// Write the body to file
+	n, err := io.Copy(file, body)
+	if err != nil {
+		return offset + n, resumable, errors.Trace(err)
+	}
+	return offset + n, resumable, nil
+}
+
+// openAt opens the file at path for writing after its first offset bytes,
+// removing anything after them. If offset is zero, the file is created.
+func openAt(path string, offset int64) (*os.File, error) {
+	if offset == 0 {
+		file, err := os.Create(path)
+		return file, errors.Trace(err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return nil, errors.Trace(err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, errors.Trace(err)
+	}
+	return file, nil
+}
+
+// checkAudio returns an error if a body with the given Content-Type is clearly
+// not audio. If the Content-Type is missing or generic, the first bytes of the
+// body are sniffed instead.
+func checkAudio(contentType string, body *bufio.Reader) error {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.HasPrefix(mediaType, "audio/"), strings.HasPrefix(mediaType, "video/"), mediaType == "application/ogg":
+		return nil
+	case strings.HasPrefix(mediaType, "text/"), strings.HasSuffix(mediaType, "json"), strings.HasSuffix(mediaType, "xml"):
+		return errors.Annotatef(errNotAudio, "expected audio but got %s", mediaType)
+	}
+
+	// Peek returns an error if the body is shorter, which is fine for sniffing
+	head, _ := body.Peek(512)
+	if sniffed := http.DetectContentType(head); strings.HasPrefix(sniffed, "text/") {
+		return errors.Annotatef(errNotAudio, "expected audio but the content looks like %s", sniffed)
+	}
+	return nil
+}
+
+// decodeBody returns a reader of the response body which undoes any gzip or
+// deflate content encoding.
+func decodeBody(response *http.Response) (io.ReadCloser, error) {
+	switch strings.ToLower(response.Header.Get("Content-Encoding")) {
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(response.Body)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return reader, nil
+	case "deflate":
+		// deflate should be zlib wrapped, but some servers send raw deflate
+		buffered := bufio.NewReader(response.Body)
+		header, err := buffered.Peek(2)
+		if err == nil && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 && header[0]&0x0f == 8 {
+			reader, err := zlib.NewReader(buffered)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			return reader, nil
+		}
+		return flate.NewReader(buffered), nil
+	}
+	return ioutil.NopCloser(response.Body), nil
+}
+
+func filePathFromURL(url string) string {
+	tokens := strings.Split(url, "/")
+	filePath := tokens[len(tokens)-1]
+	filePath = strings.Split(filePath, "?")[0]
+
+	// ensure the filePath is unique by appending timestamp
+	filePath = filePath + strconv.Itoa(int(time.Now().UnixNano()))
+	return filePath
+}
@@ -0,0 +1,421 @@
+package transcription
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBasicAuthServer(username, password, body string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/audio.flac", func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if !ok || u != username || p != password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(body))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/audio.flac", http.StatusFound)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestDownloadFileWithAuth(t *testing.T) {
+	assert := assert.New(t)
+	server := newBasicAuthServer("user", "pass", "audio")
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	dest := filepath.Join(dir, "audio.flac")
+
+	err = DownloadFileWithAuth(server.URL+"/audio.flac", dest, "user", "pass")
+	assert.NoError(err)
+	contents, _ := ioutil.ReadFile(dest)
+	assert.Equal("audio", string(contents))
+
+	// auth is preserved when redirected to the same host
+	err = DownloadFileWithAuth(server.URL+"/redirect", dest, "user", "pass")
+	assert.NoError(err)
+}
+
+func TestDownloadFileWithAuthReturnsErrorOnBadCredentials(t *testing.T) {
+	assert := assert.New(t)
+	server := newBasicAuthServer("user", "pass", "audio")
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	err = DownloadFileWithAuth(server.URL+"/audio.flac", filepath.Join(dir, "audio.flac"), "user", "wrong")
+	assert.Error(err)
+}
+
+func TestDownloadFileFromURLDecodesContentEncoding(t *testing.T) {
+	assert := assert.New(t)
+	original := bytes.Repeat([]byte("not really audio "), 100)
+
+	encoders := map[string]func(io.Writer) io.WriteCloser{
+		"gzip":    func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate": func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+	}
+
+	for encoding, newWriter := range encoders {
+		var encoded bytes.Buffer
+		w := newWriter(&encoded)
+		w.Write(original)
+		w.Close()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", encoding)
+			w.Write(encoded.Bytes())
+		}))
+
+		filePath, err := DownloadFileFromURL(server.URL + "/audio.flac")
+		server.Close()
+		assert.NoError(err)
+		contents, _ := ioutil.ReadFile(filePath)
+		os.Remove(filePath)
+		assert.Equal(original, contents, encoding)
+	}
+}
+
+func TestDownloadFileFromURLSetsUserAgent(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(userAgent string) { UserAgent = userAgent }(UserAgent)
+	UserAgent = "custom-agent/2.0"
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+
+	filePath, err := DownloadFileFromURL(server.URL + "/audio.flac")
+	assert.NoError(err)
+	os.Remove(filePath)
+	assert.Equal("custom-agent/2.0", received)
+}
+
+func TestDownloadFileWithOptionsRejectsNonAudio(t *testing.T) {
+	assert := assert.New(t)
+
+	responses := map[string]string{
+		"/page.html":  "text/html; charset=utf-8",
+		"/error.json": "application/json",
+		"/sniffed":    "",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", responses[r.URL.Path])
+		w.Write([]byte("<html><body>Not found</body></html>"))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	dest := filepath.Join(dir, "audio.flac")
+
+	for path := range responses {
+		err := DownloadFileWithOptions(server.URL+path, dest, DownloadOptions{ExpectAudio: true})
+		assert.Error(err, path)
+	}
+
+	// without ExpectAudio anything is downloaded
+	err = DownloadFileWithOptions(server.URL+"/page.html", dest, DownloadOptions{})
+	assert.NoError(err)
+}
+
+func TestDownloadFileWithOptionsAcceptsAudio(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "test.flac")
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	err = DownloadFileWithOptions(server.URL+"/test.flac", filepath.Join(dir, "audio.flac"), DownloadOptions{ExpectAudio: true})
+	assert.NoError(err)
+}
+
+func TestDownloadFileWithRetryResumes(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(delay time.Duration) { downloadRetryDelay = delay }(downloadRetryDelay)
+	downloadRetryDelay = time.Millisecond
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	var mu sync.Mutex
+	requests := 0
+	served := 0
+	ranges := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		first := requests == 1
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+
+		if first {
+			// send part of the file, then drop the connection
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write(content[:4000])
+			w.(http.Flusher).Flush()
+			mu.Lock()
+			served += 4000
+			mu.Unlock()
+			panic(http.ErrAbortHandler)
+		}
+		counter := &countingWriter{ResponseWriter: w}
+		http.ServeContent(counter, r, "audio.flac", time.Time{}, bytes.NewReader(content))
+		mu.Lock()
+		served += counter.n
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	dest := filepath.Join(dir, "audio.flac")
+
+	assert.NoError(DownloadFileWithRetry(server.URL, dest, DownloadOptions{}))
+	downloaded, err := ioutil.ReadFile(dest)
+	assert.NoError(err)
+	assert.Equal(content, downloaded)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal([]string{"", "bytes=4000-"}, ranges)
+	assert.Equal(len(content), served)
+}
+
+func TestDownloadFileWithRetryGivesUpOnClientErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	assert.Error(DownloadFileWithRetry(server.URL, filepath.Join(dir, "audio.flac"), DownloadOptions{}))
+	assert.Equal(1, requests)
+}
+
+func TestDownloadFileWithRetryHonorsRetryAfter(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(sleep func(context.Context, time.Duration) error) { downloadSleep = sleep }(downloadSleep)
+	delays := stubDownloadSleep()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "2")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("audio"))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(DownloadFileWithRetry(server.URL, filepath.Join(dir, "audio.flac"), DownloadOptions{}))
+	assert.Equal(2, requests)
+	assert.Equal([]time.Duration{2 * time.Second}, *delays)
+}
+
+// stubDownloadSleep replaces downloadSleep with a stub which records the
+// delays instead of waiting.
+func stubDownloadSleep() *[]time.Duration {
+	delays := []time.Duration{}
+	downloadSleep = func(ctx context.Context, delay time.Duration) error {
+		delays = append(delays, delay)
+		return nil
+	}
+	return &delays
+}
+
+func TestDownloadFileWithRetryCapsRetryAfter(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(sleep func(context.Context, time.Duration) error) { downloadSleep = sleep }(downloadSleep)
+	delays := stubDownloadSleep()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch requests {
+		case 1:
+			w.Header().Set("Retry-After", "86400")
+		case 2:
+			w.Header().Set("Retry-After", time.Now().AddDate(1, 0, 0).UTC().Format(http.TimeFormat))
+		default:
+			w.Write([]byte("audio"))
+			return
+		}
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(DownloadFileWithRetry(server.URL, filepath.Join(dir, "audio.flac"), DownloadOptions{}))
+	assert.Equal([]time.Duration{downloadMaxRetryDelay, downloadMaxRetryDelay}, *delays)
+}
+
+func TestDownloadFileWithRetryContextStopsWaiting(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = DownloadFileWithRetryContext(ctx, server.URL, filepath.Join(dir, "audio.flac"), DownloadOptions{})
+	assert.Equal(context.DeadlineExceeded, errors.Cause(err))
+	assert.True(time.Since(start) < time.Second)
+}
+
+func TestDownloadFileWithRetryCallsHooks(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(sleep func(context.Context, time.Duration) error) { downloadSleep = sleep }(downloadSleep)
+	stubDownloadSleep()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= 2 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("audio"))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	retries := []int{}
+	successes := []int{}
+	opts := DownloadOptions{
+		OnRetry: func(attempt int, err error) {
+			assert.Error(err)
+			retries = append(retries, attempt)
+		},
+		OnSuccess: func(attempts int) { successes = append(successes, attempts) },
+	}
+	assert.NoError(DownloadFileWithRetry(server.URL, filepath.Join(dir, "audio.flac"), opts))
+	assert.Equal([]int{1, 2}, retries)
+	assert.Equal([]int{3}, successes)
+}
+
+func TestDownloadFileWithOptionsSkipsExistingFile(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	gets := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			mu.Lock()
+			gets++
+			mu.Unlock()
+		}
+		w.Header().Set("Content-Length", "5")
+		w.Write([]byte("audio"))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	dest := filepath.Join(dir, "audio.flac")
+	opts := DownloadOptions{SkipIfExists: true}
+
+	// a file of the same size is kept
+	assert.NoError(ioutil.WriteFile(dest, []byte("saved"), 0644))
+	assert.NoError(DownloadFileWithOptions(server.URL, dest, opts))
+	contents, _ := ioutil.ReadFile(dest)
+	assert.Equal("saved", string(contents))
+	mu.Lock()
+	assert.Equal(0, gets)
+	mu.Unlock()
+
+	// a file of another size is downloaded again
+	assert.NoError(ioutil.WriteFile(dest, []byte("partial audio"), 0644))
+	assert.NoError(DownloadFileWithOptions(server.URL, dest, opts))
+	contents, _ = ioutil.ReadFile(dest)
+	assert.Equal("audio", string(contents))
+	mu.Lock()
+	assert.Equal(1, gets)
+	mu.Unlock()
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(2*time.Second, parseRetryAfter("2", now))
+	assert.Equal(90*time.Second, parseRetryAfter("Wed, 01 Mar 2017 12:01:30 GMT", now))
+	assert.Equal(time.Duration(0), parseRetryAfter("Wed, 01 Mar 2017 11:59:00 GMT", now))
+	assert.Equal(time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(time.Duration(0), parseRetryAfter("soon", now))
+	assert.Equal(time.Duration(0), parseRetryAfter("-5", now))
+}
+
+// countingWriter counts the bytes of a response body.
+type countingWriter struct {
+	http.ResponseWriter
+	n int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.n += n
+	return n, err
+}
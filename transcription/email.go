@@ -0,0 +1,383 @@
+package transcription
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"html/template"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/jordan-wright/email"
+	"github.com/juju/errors"
+)
+
+// emailDialTimeout is how long SendEmail waits to connect to the email server
+// and receive its greeting.
+var emailDialTimeout = 30 * time.Second
+
+// defaultMaxMessageBytes is the default size limit of an email with
+// attachments, the limit of Gmail.
+const defaultMaxMessageBytes = 25 << 20
+
+// EmailConfig contains the settings of an email account used to send email.
+type EmailConfig struct {
+	Username string
+	Password string
+	Host     string
+	Port     int
+	// MaxMessageBytes is the size limit of an email sent by
+	// SendEmailWithAttachments, since servers reject larger emails. Defaults
+	// to 25MB.
+	MaxMessageBytes int
+}
+
+func (cfg EmailConfig) maxMessageBytes() int {
+	if cfg.MaxMessageBytes > 0 {
+		return cfg.MaxMessageBytes
+	}
+	return defaultMaxMessageBytes
+}
+
+func (cfg EmailConfig) addr() string {
+	return cfg.Host + ":" + strconv.Itoa(cfg.Port)
+}
+
+// auth returns the authentication with the credentials of cfg, or nil if it
+// has none.
+func (cfg EmailConfig) auth() smtp.Auth {
+	if cfg.Username == "" {
+		return nil
+	}
+	return smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+}
+
+// SendEmail connects to an email server at host:port and sends an email from
+// address from, to address to, with subject line subject with message body.
+func SendEmail(username string, password string, host string, port int, to []string, subject string, body string) error {
+	return tracePhase(SendEmailContext(context.Background(), username, password, host, port, to, subject, body))
+}
+
+// SendEmailContext works like SendEmail, but gives up with ctx.Err() once ctx
+// is done, whether it is connecting, authenticating or sending the message.
+// Both return an *EmailError if sending fails.
+func SendEmailContext(ctx context.Context, username string, password string, host string, port int, to []string, subject string, body string) error {
+	cfg := EmailConfig{
+		Username: username,
+		Password: password,
+		Host:     host,
+		Port:     port,
+	}
+	raw, err := newEmailMessage(username, to, subject, body)
+	if err != nil {
+		return &EmailError{To: to, Err: errors.Trace(err)}
+	}
+	if err := sendMail(ctx, cfg.addr(), host, cfg.auth(), username, to, raw); err != nil {
+		return &EmailError{To: to, Err: errors.Trace(err)}
+	}
+	return nil
+}
+
+// SendEmailWithAttachments sends an email like SendEmailContext with the
+// files at attachmentPaths attached. It fails without connecting to the
+// server if the email would be larger than cfg.MaxMessageBytes. It returns an
+// *EmailError if sending fails.
+func SendEmailWithAttachments(ctx context.Context, cfg EmailConfig, to []string, subject string, body string, attachmentPaths []string) error {
+	size := len(body)
+	for _, path := range attachmentPaths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return &EmailError{To: to, Err: errors.Trace(err)}
+		}
+		size += base64Size(info.Size())
+	}
+	if size > cfg.maxMessageBytes() {
+		err := errors.Errorf("the email with %d attachments would be about %d bytes, more than the limit of %d bytes", len(attachmentPaths), size, cfg.maxMessageBytes())
+		return &EmailError{To: to, Err: err}
+	}
+
+	message := newEmail(cfg.Username, to, subject, body)
+	for _, path := range attachmentPaths {
+		if _, err := message.AttachFile(path); err != nil {
+			return &EmailError{To: to, Err: errors.Trace(err)}
+		}
+	}
+	raw, err := message.Bytes()
+	if err != nil {
+		return &EmailError{To: to, Err: errors.Trace(err)}
+	}
+	if err := sendMail(ctx, cfg.addr(), cfg.Host, cfg.auth(), cfg.Username, to, raw); err != nil {
+		return &EmailError{To: to, Err: errors.Trace(err)}
+	}
+	return nil
+}
+
+// base64Size returns the size of n bytes encoded as base64 in lines of 76
+// characters, as attachments are.
+func base64Size(n int64) int {
+	encoded := int((n + 2) / 3 * 4)
+	return encoded + (encoded+75)/76*2
+}
+
+// newEmail returns a plain text email. The body is sent as UTF-8 and the email
+// library encodes a subject with non-ASCII characters as described in RFC 2047.
+func newEmail(from string, to []string, subject string, body string) *email.Email {
+	return &email.Email{
+		From:    from,
+		To:      to,
+		Subject: subject,
+		Text:    []byte(body),
+	}
+}
+
+// SendHTMLEmail sends an email like SendEmailContext whose HTML is tmpl
+// executed with data, as multipart/alternative with body as its plain text.
+// If tmpl fails, the error is logged and the email is sent as plain text
+// only, so that the notification still goes out. It returns an *EmailError if
+// sending fails.
+func SendHTMLEmail(ctx context.Context, cfg EmailConfig, to []string, subject string, body string, tmpl *template.Template, data interface{}) error {
+	raw, err := newHTMLEmailMessage(cfg.Username, to, subject, body, tmpl, data)
+	if err != nil {
+		return &EmailError{To: to, Err: errors.Trace(err)}
+	}
+	if err := sendMail(ctx, cfg.addr(), cfg.Host, cfg.auth(), cfg.Username, to, raw); err != nil {
+		return &EmailError{To: to, Err: errors.Trace(err)}
+	}
+	return nil
+}
+
+// newHTMLEmailMessage returns the raw bytes of an email with text and the
+// HTML of tmpl executed with data, or of a plain text email if tmpl fails.
+func newHTMLEmailMessage(from string, to []string, subject string, text string, tmpl *template.Template, data interface{}) ([]byte, error) {
+	message := newEmail(from, to, subject, text)
+	var html bytes.Buffer
+	if err := tmpl.Execute(&html, data); err != nil {
+		log.Warnf("Sending email %q as plain text since its HTML template failed: %v", subject, err)
+	} else {
+		message.HTML = html.Bytes()
+	}
+	raw, err := message.Bytes()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return raw, nil
+}
+
+// newEmailMessage returns the raw bytes of the plain text email of newEmail.
+func newEmailMessage(from string, to []string, subject string, body string) ([]byte, error) {
+	raw, err := newEmail(from, to, subject, body).Bytes()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return raw, nil
+}
+
+// sendMail works like smtp.SendMail, but fails if the server at addr does not
+// greet us within emailDialTimeout or if ctx is done first.
+func sendMail(ctx context.Context, addr string, host string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	return withSMTPClient(ctx, addr, host, auth, func(c *smtp.Client) error {
+		return errors.Trace(sendSMTPMessage(c, from, to, msg))
+	})
+}
+
+// withSMTPClient connects and authenticates to the server at addr, calls send
+// with the client and then ends the session. It fails if the server does not
+// greet us within emailDialTimeout or if ctx is done first.
+func withSMTPClient(ctx context.Context, addr string, host string, auth smtp.Auth, send func(c *smtp.Client) error) error {
+	dialer := net.Dialer{Timeout: emailDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	// closing the connection makes any pending read or write fail
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	err = func() error {
+		c, err := newSMTPClient(conn, host, auth)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		defer c.Close()
+
+		if err := send(c); err != nil {
+			return errors.Trace(err)
+		}
+		return errors.Trace(c.Quit())
+	}()
+	if err != nil && ctx.Err() != nil {
+		return errors.Trace(ctx.Err())
+	}
+	return errors.Trace(err)
+}
+
+// dialSMTP connects to the email server at addr and authenticates. It fails
+// if the server does not greet us within emailDialTimeout.
+func dialSMTP(addr string, host string, auth smtp.Auth) (*smtp.Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, emailDialTimeout)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	c, err := newSMTPClient(conn, host, auth)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return c, nil
+}
+
+// newSMTPClient starts an SMTP session over conn and authenticates if auth is
+// not nil. It fails if the server does not greet us within emailDialTimeout
+// or, like smtp.SendMail, if auth is given but the server does not support
+// AUTH. conn is closed if it fails.
+func newSMTPClient(conn net.Conn, host string, auth smtp.Auth) (*smtp.Client, error) {
+	conn.SetDeadline(time.Now().Add(emailDialTimeout))
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, errors.Trace(err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			c.Close()
+			return nil, errors.Trace(err)
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			c.Close()
+			return nil, errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(auth); err != nil {
+			c.Close()
+			return nil, errors.Trace(err)
+		}
+	}
+	return c, nil
+}
+
+// sendSMTPMessage sends msg over an established connection.
+func sendSMTPMessage(c *smtp.Client, from string, to []string, msg []byte) error {
+	if err := c.Mail(from); err != nil {
+		return errors.Trace(err)
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(w.Close())
+}
+
+// SendEmailWithReceipts sends an email like SendEmailContext, but does not
+// give up if the server rejects some of the addresses in to. It returns the
+// reply of the server to each address, which is nil if the server accepted
+// it. The email is sent to the accepted addresses. The error is an
+// *EmailError if sending fails, such as if every address is rejected.
+func SendEmailWithReceipts(ctx context.Context, cfg EmailConfig, to []string, subject string, body string) (map[string]error, error) {
+	raw, err := newEmailMessage(cfg.Username, to, subject, body)
+	if err != nil {
+		return nil, &EmailError{To: to, Err: errors.Trace(err)}
+	}
+	var receipts map[string]error
+	err = withSMTPClient(ctx, cfg.addr(), cfg.Host, cfg.auth(), func(c *smtp.Client) error {
+		var err error
+		receipts, err = sendSMTPMessageWithReceipts(c, cfg.Username, to, raw)
+		return errors.Trace(err)
+	})
+	if err != nil {
+		return receipts, &EmailError{To: to, Err: errors.Trace(err)}
+	}
+	return receipts, nil
+}
+
+// sendSMTPMessageWithReceipts sends msg over an established connection to the
+// addresses in to which the server accepts, and returns the reply to each
+// address. It fails if no address is accepted.
+func sendSMTPMessageWithReceipts(c *smtp.Client, from string, to []string, msg []byte) (map[string]error, error) {
+	if err := c.Mail(from); err != nil {
+		return nil, errors.Trace(err)
+	}
+	receipts := make(map[string]error, len(to))
+	accepted := 0
+	for _, addr := range to {
+		err := c.Rcpt(addr)
+		receipts[addr] = err
+		if err == nil {
+			accepted++
+		}
+	}
+	if accepted == 0 {
+		c.Reset()
+		return receipts, errors.New("the server rejected every recipient")
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return receipts, errors.Trace(err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return receipts, errors.Trace(err)
+	}
+	return receipts, errors.Trace(w.Close())
+}
+
+// SMTPClient sends emails over a single authenticated connection to an email
+// server, which is faster than connecting for every email.
+type SMTPClient struct {
+	cfg    EmailConfig
+	client *smtp.Client
+}
+
+// NewSMTPClient connects and authenticates to the email server of cfg.
+func NewSMTPClient(cfg EmailConfig) (*SMTPClient, error) {
+	c, err := dialSMTP(cfg.addr(), cfg.Host, cfg.auth())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &SMTPClient{cfg: cfg, client: c}, nil
+}
+
+// Send sends an email from the configured account to the addresses in to.
+func (c *SMTPClient) Send(to []string, subject string, body string) error {
+	raw, err := newEmailMessage(c.cfg.Username, to, subject, body)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := sendSMTPMessage(c.client, c.cfg.Username, to, raw); err != nil {
+		// abort the transaction so that the connection can be used again
+		c.client.Reset()
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+// Close ends the connection to the email server.
+func (c *SMTPClient) Close() error {
+	if err := c.client.Quit(); err != nil {
+		c.client.Close()
+		return errors.Trace(err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package transcription
+
+import (
+	"context"
+	"net/textproto"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/juju/errors"
+)
+
+var (
+	// emailRetryDelay is how long an EmailQueue waits before retrying an email
+	// for the first time. The delay doubles after every failed attempt.
+	emailRetryDelay = 30 * time.Second
+	// emailMaxAttempts is how many times an EmailQueue tries to send an email
+	// before giving up on it.
+	emailMaxAttempts = 5
+)
+
+// EmailMessage is an email waiting in an EmailQueue.
+type EmailMessage struct {
+	To      []string
+	Subject string
+	Body    string
+	// Attempts is the number of times sending the email failed.
+	Attempts int
+	// Err is the error of the last attempt.
+	Err error
+
+	retryAt time.Time
+}
+
+// EmailQueue sends emails in the background. Emails which fail temporarily,
+// such as when the email server replies with a 4xx code, are retried with
+// exponential backoff. Emails which fail permanently, with a 5xx code, or too
+// many times are moved to the dead letters.
+type EmailQueue struct {
+	cfg     EmailConfig
+	mu      sync.Mutex
+	pending []*EmailMessage
+	dead    []*EmailMessage
+	wake    chan struct{}
+}
+
+// NewEmailQueue returns an empty queue which sends emails with cfg.
+func NewEmailQueue(cfg EmailConfig) *EmailQueue {
+	return &EmailQueue{cfg: cfg, wake: make(chan struct{}, 1)}
+}
+
+// Enqueue adds an email to the queue. It is sent by Run.
+func (q *EmailQueue) Enqueue(to []string, subject string, body string) {
+	q.mu.Lock()
+	q.pending = append(q.pending, &EmailMessage{To: to, Subject: subject, Body: body})
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// DeadLetters returns the emails which could not be sent.
+func (q *EmailQueue) DeadLetters() []EmailMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	dead := make([]EmailMessage, 0, len(q.dead))
+	for _, message := range q.dead {
+		dead = append(dead, *message)
+	}
+	return dead
+}
+
+// Run sends queued emails until ctx is done.
+func (q *EmailQueue) Run(ctx context.Context) error {
+	for {
+		next := q.sendDue(ctx, time.Now())
+
+		var timer *time.Timer
+		var retry <-chan time.Time
+		if !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			retry = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return ctx.Err()
+		case <-q.wake:
+		case <-retry:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// sendDue tries to send every email which is due at now. It returns when the
+// next email is due, or the zero time if the queue is empty. Sending gives up
+// once ctx is done.
+func (q *EmailQueue) sendDue(ctx context.Context, now time.Time) time.Time {
+	q.mu.Lock()
+	due := []*EmailMessage{}
+	waiting := []*EmailMessage{}
+	for _, message := range q.pending {
+		if message.retryAt.After(now) {
+			waiting = append(waiting, message)
+		} else {
+			due = append(due, message)
+		}
+	}
+	q.pending = waiting
+	q.mu.Unlock()
+
+	for _, message := range due {
+		q.send(ctx, message)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	next := time.Time{}
+	for _, message := range q.pending {
+		if next.IsZero() || message.retryAt.Before(next) {
+			next = message.retryAt
+		}
+	}
+	return next
+}
+
+// send sends message and requeues it or moves it to the dead letters if
+// sending fails. A message whose sending is interrupted by ctx is requeued
+// without counting the attempt.
+func (q *EmailQueue) send(ctx context.Context, message *EmailMessage) {
+	err := sendEmail(ctx, q.cfg.Username, q.cfg.Password, q.cfg.Host, q.cfg.Port, message.To, message.Subject, message.Body)
+	if err == nil {
+		return
+	}
+	if ctx.Err() != nil {
+		q.mu.Lock()
+		q.pending = append(q.pending, message)
+		q.mu.Unlock()
+		return
+	}
+	message.Attempts++
+	message.Err = err
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if isPermanentEmailError(err) || message.Attempts >= emailMaxAttempts {
+		log.Errorf("Giving up on email %q to %v: %v", message.Subject, message.To, err)
+		q.dead = append(q.dead, message)
+		return
+	}
+	delay := emailRetryDelay << uint(message.Attempts-1)
+	log.Warnf("Could not send email %q to %v, retrying in %v: %v", message.Subject, message.To, delay, err)
+	message.retryAt = time.Now().Add(delay)
+	q.pending = append(q.pending, message)
+}
+
+// isPermanentEmailError returns whether the email server rejected an email
+// with a 5xx code. Other errors, such as 4xx codes or network errors, may go
+// away if the email is sent again.
+func isPermanentEmailError(err error) bool {
+	if protoErr, ok := errors.Cause(err).(*textproto.Error); ok {
+		return protoErr.Code >= 500
+	}
+	return false
+}
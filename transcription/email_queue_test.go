@@ -0,0 +1,84 @@
+package transcription
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// runEmailQueue runs q until fn returns true or a second passes.
+func runEmailQueue(q *EmailQueue, fn func() bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(done)
+	}()
+	for start := time.Now(); !fn() && time.Since(start) < time.Second; {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestEmailQueueRetriesTemporaryFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(delay time.Duration) { emailRetryDelay = delay }(emailRetryDelay)
+	emailRetryDelay = 10 * time.Millisecond
+
+	server := newMockSMTPServer(t)
+	defer server.Close()
+	var mu sync.Mutex
+	attempts := 0
+	server.rcptReply = func(addr string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			return "421 Service not available, try again later"
+		}
+		return ""
+	}
+
+	q := NewEmailQueue(server.config())
+	q.Enqueue([]string{"to@email.com"}, "subject", "body")
+	runEmailQueue(q, func() bool {
+		server.Lock()
+		defer server.Unlock()
+		return len(server.messages) == 1
+	})
+
+	server.Lock()
+	assert.Len(server.messages, 1)
+	server.Unlock()
+	mu.Lock()
+	assert.Equal(2, attempts)
+	mu.Unlock()
+	assert.Empty(q.DeadLetters())
+}
+
+func TestEmailQueueDropsPermanentFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newMockSMTPServer(t)
+	defer server.Close()
+	server.rcptReply = func(addr string) string {
+		return "550 No such user"
+	}
+
+	q := NewEmailQueue(server.config())
+	q.Enqueue([]string{"nobody@email.com"}, "subject", "body")
+	runEmailQueue(q, func() bool {
+		return len(q.DeadLetters()) == 1
+	})
+
+	dead := q.DeadLetters()
+	if assert.Len(dead, 1) {
+		assert.Equal([]string{"nobody@email.com"}, dead[0].To)
+		assert.Equal(1, dead[0].Attempts)
+	}
+}
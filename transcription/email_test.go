@@ -0,0 +1,338 @@
+package transcription
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"html/template"
+	"mime"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockSMTPServer is a minimal email server which accepts any credentials.
+type mockSMTPServer struct {
+	sync.Mutex
+	listener net.Listener
+	// dials is the number of connections made to the server.
+	dials int
+	// messages are the DATA of every accepted email.
+	messages []string
+	// rcptReply returns the reply to RCPT TO for an address, if set.
+	rcptReply func(addr string) string
+	// dataReply returns the reply to a completed DATA, if set.
+	dataReply func(message string) string
+	// noAuth leaves AUTH out of the extensions of the server.
+	noAuth bool
+}
+
+func newMockSMTPServer(t *testing.T) *mockSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &mockSMTPServer{listener: listener}
+	go s.serve()
+	return s
+}
+
+// config returns an EmailConfig for the server.
+func (s *mockSMTPServer) config() EmailConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return EmailConfig{Username: "from@email.com", Password: "123456", Host: host, Port: p}
+}
+
+func (s *mockSMTPServer) Close() {
+	s.listener.Close()
+}
+
+func (s *mockSMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.Lock()
+		s.dials++
+		s.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *mockSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSpace(line)
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch command {
+		case "EHLO", "HELO":
+			s.Lock()
+			noAuth := s.noAuth
+			s.Unlock()
+			if noAuth {
+				reply("250 localhost")
+				continue
+			}
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			reply("235 Authentication successful")
+		case "RCPT":
+			addr := strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>")
+			if s.rcptReply != nil {
+				if r := s.rcptReply(addr); r != "" {
+					reply(r)
+					continue
+				}
+			}
+			reply("250 OK")
+		case "DATA":
+			reply("354 Go ahead")
+			var message []string
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				message = append(message, line)
+			}
+			data := strings.Join(message, "")
+			if s.dataReply != nil {
+				if r := s.dataReply(data); r != "" {
+					reply(r)
+					continue
+				}
+			}
+			s.Lock()
+			s.messages = append(s.messages, data)
+			s.Unlock()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSendEmailTimesOutOnUnreachableServer(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(timeout time.Duration) { emailDialTimeout = timeout }(emailDialTimeout)
+	emailDialTimeout = 200 * time.Millisecond
+
+	start := time.Now()
+	// 10.255.255.1 is a non-routable address, so no server ever greets us
+	err := SendEmail("test@email.com", "123456", "10.255.255.1", 25, []string{"to@email.com"}, "subject", "body")
+	assert.Error(err)
+	assert.True(time.Since(start) < 5*time.Second)
+}
+
+func TestSendEmailWithMockServer(t *testing.T) {
+	assert := assert.New(t)
+	server := newMockSMTPServer(t)
+	defer server.Close()
+
+	cfg := server.config()
+	err := SendEmail(cfg.Username, cfg.Password, cfg.Host, cfg.Port, []string{"to@email.com"}, "subject", "body")
+	assert.NoError(err)
+	assert.Len(server.messages, 1)
+}
+
+func TestSendEmailFailsIfServerDoesNotSupportAuth(t *testing.T) {
+	assert := assert.New(t)
+	server := newMockSMTPServer(t)
+	defer server.Close()
+	server.Lock()
+	server.noAuth = true
+	server.Unlock()
+
+	cfg := server.config()
+	err := SendEmail(cfg.Username, cfg.Password, cfg.Host, cfg.Port, []string{"to@email.com"}, "subject", "body")
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "doesn't support AUTH")
+	}
+	server.Lock()
+	defer server.Unlock()
+	assert.Empty(server.messages)
+}
+
+func TestSendEmailContextAbortsSlowData(t *testing.T) {
+	assert := assert.New(t)
+	server := newMockSMTPServer(t)
+	defer server.Close()
+	server.dataReply = func(message string) string {
+		time.Sleep(2 * time.Second)
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	cfg := server.config()
+	err := SendEmailContext(ctx, cfg.Username, cfg.Password, cfg.Host, cfg.Port, []string{"to@email.com"}, "subject", "body")
+	assert.Equal(context.DeadlineExceeded, errors.Cause(err))
+	assert.True(time.Since(start) < time.Second)
+}
+
+func TestSendEmailWithReceiptsReportsRejectedRecipients(t *testing.T) {
+	assert := assert.New(t)
+	server := newMockSMTPServer(t)
+	defer server.Close()
+	server.rcptReply = func(addr string) string {
+		if addr == "nobody@email.com" {
+			return "550 No such user"
+		}
+		return ""
+	}
+
+	to := []string{"to@email.com", "nobody@email.com"}
+	receipts, err := SendEmailWithReceipts(context.Background(), server.config(), to, "subject", "body")
+	assert.NoError(err)
+	assert.Len(receipts, 2)
+	assert.NoError(receipts["to@email.com"])
+	if reply, ok := receipts["nobody@email.com"].(*textproto.Error); assert.True(ok) {
+		assert.Equal(550, reply.Code)
+	}
+	server.Lock()
+	defer server.Unlock()
+	assert.Len(server.messages, 1)
+}
+
+func TestSendEmailWithReceiptsFailsIfEveryRecipientIsRejected(t *testing.T) {
+	assert := assert.New(t)
+	server := newMockSMTPServer(t)
+	defer server.Close()
+	server.rcptReply = func(addr string) string {
+		return "550 No such user"
+	}
+
+	receipts, err := SendEmailWithReceipts(context.Background(), server.config(), []string{"nobody@email.com"}, "subject", "body")
+	assert.Error(err)
+	assert.Error(receipts["nobody@email.com"])
+	server.Lock()
+	defer server.Unlock()
+	assert.Empty(server.messages)
+}
+
+func TestSMTPClientReusesConnection(t *testing.T) {
+	assert := assert.New(t)
+	server := newMockSMTPServer(t)
+	defer server.Close()
+
+	client, err := NewSMTPClient(server.config())
+	assert.NoError(err)
+	assert.NoError(client.Send([]string{"one@email.com"}, "first", "body"))
+	assert.NoError(client.Send([]string{"two@email.com"}, "second", "body"))
+	assert.NoError(client.Close())
+
+	server.Lock()
+	defer server.Unlock()
+	assert.Equal(1, server.dials)
+	assert.Len(server.messages, 2)
+}
+
+func TestSendEmailWithAttachments(t *testing.T) {
+	assert := assert.New(t)
+	server := newMockSMTPServer(t)
+	defer server.Close()
+
+	attachment := writeTempFile(t, []byte("transcript"))
+	defer os.Remove(attachment)
+
+	err := SendEmailWithAttachments(context.Background(), server.config(), []string{"to@email.com"}, "subject", "body", []string{attachment})
+	assert.NoError(err)
+	server.Lock()
+	defer server.Unlock()
+	if assert.Len(server.messages, 1) {
+		assert.Contains(server.messages[0], "Content-Disposition: attachment")
+	}
+}
+
+func TestSendEmailWithAttachmentsRejectsLargeEmail(t *testing.T) {
+	assert := assert.New(t)
+	server := newMockSMTPServer(t)
+	defer server.Close()
+
+	first := writeTempFile(t, make([]byte, 600))
+	defer os.Remove(first)
+	second := writeTempFile(t, make([]byte, 600))
+	defer os.Remove(second)
+
+	cfg := server.config()
+	cfg.MaxMessageBytes = 1000
+	err := SendEmailWithAttachments(context.Background(), cfg, []string{"to@email.com"}, "subject", "body", []string{first, second})
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "more than the limit of 1000 bytes")
+	}
+	server.Lock()
+	defer server.Unlock()
+	assert.Equal(0, server.dials)
+}
+
+func TestNewEmailMessageEncodesUTF8(t *testing.T) {
+	assert := assert.New(t)
+
+	subject := "Transcript of 会議.mp3"
+	raw, err := newEmailMessage("from@email.com", []string{"to@email.com"}, subject, "こんにちは")
+	assert.NoError(err)
+	assert.Contains(string(raw), "text/plain; charset=UTF-8")
+
+	message, err := mail.ReadMessage(bytes.NewReader(raw))
+	if assert.NoError(err) {
+		decoded, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+		assert.NoError(err)
+		assert.Equal(subject, decoded)
+	}
+}
+
+func TestNewHTMLEmailMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpl := template.Must(template.New("email").Parse(`<p>{{.Name}} is done</p>`))
+	raw, err := newHTMLEmailMessage("from@email.com", []string{"to@email.com"}, "subject", "body", tmpl, struct{ Name string }{"test.flac"})
+	assert.NoError(err)
+	message := string(raw)
+	assert.Contains(message, "multipart/alternative")
+	assert.Contains(message, "text/html")
+	assert.Contains(message, "<p>test.flac is done</p>")
+}
+
+func TestNewHTMLEmailMessageFallsBackToPlainText(t *testing.T) {
+	assert := assert.New(t)
+
+	// the template fails since Name has no field Missing
+	tmpl := template.Must(template.New("email").Parse(`<p>{{.Name.Missing}}</p>`))
+	raw, err := newHTMLEmailMessage("from@email.com", []string{"to@email.com"}, "subject", "body", tmpl, struct{ Name string }{"test.flac"})
+	assert.NoError(err)
+	message := string(raw)
+	assert.Contains(message, "text/plain")
+	assert.Contains(message, "body")
+	assert.NotContains(message, "text/html")
+}
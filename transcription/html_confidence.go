@@ -0,0 +1,49 @@
+package transcription
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+)
+
+// Words with at least these confidences get the classes confidence-high and
+// confidence-medium of ToHTMLConfidence. Other words get confidence-low.
+const (
+	highConfidence   = 0.8
+	mediumConfidence = 0.5
+)
+
+// ToHTMLConfidence returns the final segments of r as HTML for reviewing a
+// transcript, with a paragraph per segment. Each word is a span colored from
+// red, for a confidence of 0, to green, for a confidence of 1, with the class
+// confidence-high, confidence-medium or confidence-low for custom styles. The
+// data-start and data-end attributes of a span are the time of the word in
+// seconds, such as for seeking an audio element to the word when it is
+// clicked.
+func (r *IBMResult) ToHTMLConfidence() string {
+	var buffer bytes.Buffer
+	for _, segment := range r.ToTranscript().Segments {
+		buffer.WriteString(`<p class="segment">`)
+		for i, word := range segment.Words {
+			if i > 0 {
+				buffer.WriteString(" ")
+			}
+			fmt.Fprintf(&buffer, `<span class="%s" style="color: hsl(%.0f, 70%%, 35%%)" data-start="%.3f" data-end="%.3f" data-confidence="%.2f">%s</span>`,
+				confidenceClass(word.Confidence), 120*word.Confidence, word.Start, word.End, word.Confidence, html.EscapeString(word.Text))
+		}
+		buffer.WriteString("</p>\n")
+	}
+	return buffer.String()
+}
+
+// confidenceClass returns the class of a word with confidence in
+// ToHTMLConfidence.
+func confidenceClass(confidence float64) string {
+	switch {
+	case confidence >= highConfidence:
+		return "confidence-high"
+	case confidence >= mediumConfidence:
+		return "confidence-medium"
+	}
+	return "confidence-low"
+}
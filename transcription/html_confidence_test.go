@@ -0,0 +1,26 @@
+package transcription
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToHTMLConfidence(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{{"fish", 0.5, 1.0}, {"&", 1.0, 1.2}},
+		[]ibmWordTimestamp{{"chips", 2.0, 2.5}},
+	)
+	res.Results[0].Alternatives[0].WordConfidence = []ibmWordConfidence{{"fish", 0.95}, {"&", 0.6}}
+	res.Results[1].Alternatives[0].WordConfidence = []ibmWordConfidence{{"chips", 0.2}}
+
+	html := res.ToHTMLConfidence()
+	assert.Equal(2, strings.Count(html, `<p class="segment">`))
+	assert.Contains(html, `<span class="confidence-high" style="color: hsl(114, 70%, 35%)" data-start="0.500" data-end="1.000" data-confidence="0.95">fish</span>`)
+	assert.Contains(html, `class="confidence-medium"`)
+	assert.Contains(html, `data-start="1.000" data-end="1.200" data-confidence="0.60">&amp;</span>`)
+	assert.Contains(html, `<span class="confidence-low" style="color: hsl(24, 70%, 35%)" data-start="2.000"`)
+}
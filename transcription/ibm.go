@@ -1,12 +1,15 @@
 package transcription
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"io"
+	"io/fs"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -18,9 +21,27 @@ import (
 // https://www.ibm.com/smarterplanet/us/en/ibmwatson/developercloud/doc/speech-to-text/output.shtml
 // for details.
 type IBMResult struct {
-	ResultIndex int              `json:"result_index"`
-	Results     []ibmResultField `json:"results"`
+	ResultIndex   int               `json:"result_index"`
+	Results       []ibmResultField  `json:"results"`
+	SpeakerLabels []ibmSpeakerLabel `json:"speaker_labels"`
+	// Warnings are sent by IBM about the request, such as about unknown
+	// parameters.
+	Warnings []string `json:"warnings"`
+	// Model is the IBM model which made the result, such as
+	// en-US_BroadbandModel. It is set by this package, not sent by IBM, and is
+	// empty if the result was not made by a single model.
+	Model string `json:"model,omitempty"`
 }
+
+// Language returns the language of the model which made r, such as en-US, or
+// an empty string if it is unknown.
+func (r *IBMResult) Language() string {
+	if i := strings.Index(r.Model, "_"); i > 0 {
+		return r.Model[:i]
+	}
+	return ""
+}
+
 type ibmResultField struct {
 	Alternatives []ibmAlternativesField        `json:"alternatives"`
 	KeywordMap   map[string][]ibmKeywordResult `json:"keywords_result"`
@@ -32,6 +53,13 @@ type ibmAlternativesField struct {
 	Transcript        string              `json:"transcript"`
 	Timestamps        []ibmWordTimestamp  `json:"timestamps"`
 }
+type ibmSpeakerLabel struct {
+	From       float64 `json:"from"`
+	To         float64 `json:"to"`
+	Speaker    int     `json:"speaker"`
+	Confidence float64 `json:"confidence"`
+	Final      bool    `json:"final"`
+}
 type ibmWordConfidence [2]interface{}
 type ibmWordTimestamp [3]interface{}
 
@@ -43,66 +71,305 @@ type ibmKeywordResult struct {
 }
 
 // TranscribeWithIBM transcribes a given audio file using the IBM Watson
-// Speech To Text API
-func TranscribeWithIBM(filePath string, searchWords []string, IBMUsername string, IBMPassword string) (*IBMResult, error) {
-	result := new(IBMResult)
+// Speech To Text API at its default url. It returns a *TranscribeError if
+// transcription fails. Use TranscribeWithIBMContext to give IBMCredentials
+// with another url.
+func TranscribeWithIBM(filePath string, searchWords []string, IBMUsername string, IBMPassword string, opts IBMOptions) (*IBMResult, error) {
+	creds := IBMCredentials{Username: IBMUsername, Password: IBMPassword}
+	return TranscribeWithIBMContext(context.Background(), filePath, searchWords, creds, opts)
+}
 
-	url := "wss://stream.watsonplatform.net/speech-to-text/api/v1/recognize?model=en-US_BroadbandModel"
-	header := http.Header{}
-	header.Set("Authorization", "Basic "+basicAuth(IBMUsername, IBMPassword))
+// TranscribeWithIBMContext works like TranscribeWithIBM with creds, and stops
+// the upload and recognition as soon as ctx is done.
+func TranscribeWithIBMContext(ctx context.Context, filePath string, searchWords []string, creds IBMCredentials, opts IBMOptions) (*IBMResult, error) {
+	opts = opts.withModelForFile(filePath)
+	audioPath := filePath
+	if opts.LoudnessTarget != 0 && !opts.Follow {
+		normalized, err := NormalizeLoudness(filePath, opts.LoudnessTarget)
+		if err != nil {
+			return nil, &TranscribeError{Path: filePath, Err: errors.Trace(err)}
+		}
+		defer os.Remove(normalized)
+		audioPath = normalized
+	}
+	open := func() (io.ReadCloser, error) {
+		file, err := os.Open(audioPath)
+		if err != nil || !opts.Follow {
+			return file, err
+		}
+		return &followReader{file: file, timeout: opts.followTimeout(), lastRead: time.Now()}, nil
+	}
+	res, err := transcribeWithIBM(ctx, open, searchWords, creds, opts)
+	if err != nil {
+		return nil, &TranscribeError{Path: filePath, Err: errors.Trace(err)}
+	}
+	return res, nil
+}
 
-	dialer := websocket.DefaultDialer
-	ws, _, err := dialer.Dial(url, header)
+// TranscribeReaderWithIBM transcribes the audio read from r, whose format is
+// given by opts.ContentType, using the IBM Watson Speech To Text API. The audio
+// is streamed to IBM as it is read, so r can be a pipe. Unlike
+// TranscribeWithIBM, it cannot reconnect if the connection to IBM drops, since
+// the audio cannot be read again. It returns a *TranscribeError if
+// transcription fails.
+func TranscribeReaderWithIBM(ctx context.Context, r io.Reader, searchWords []string, creds IBMCredentials, opts IBMOptions) (*IBMResult, error) {
+	read := false
+	open := func() (io.ReadCloser, error) {
+		if read {
+			return nil, errors.New("cannot read the audio again to reconnect to IBM")
+		}
+		read = true
+		return ioutil.NopCloser(r), nil
+	}
+	res, err := transcribeWithIBM(ctx, open, searchWords, creds, opts)
 	if err != nil {
-		return nil, errors.Trace(err)
+		return nil, &TranscribeError{Err: errors.Trace(err)}
+	}
+	return res, nil
+}
+
+// TranscribeFileWithIBM transcribes the audio of f, whose format is given by
+// contentType, using the IBM Watson Speech To Text API. f can be any fs.File,
+// such as one opened from an embed.FS. If f implements io.Seeker, the audio is
+// read again from the start to reconnect if the connection to IBM drops. It
+// does not close f, and returns a *TranscribeError if transcription fails.
+func TranscribeFileWithIBM(ctx context.Context, f fs.File, contentType string, creds IBMCredentials) (*IBMResult, error) {
+	name := ""
+	if info, err := f.Stat(); err == nil {
+		name = info.Name()
+	}
+	read := false
+	open := func() (io.ReadCloser, error) {
+		if read {
+			seeker, ok := f.(io.Seeker)
+			if !ok {
+				return nil, errors.New("cannot read the audio again to reconnect to IBM")
+			}
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+		read = true
+		return ioutil.NopCloser(f), nil
+	}
+	res, err := transcribeWithIBM(ctx, open, nil, creds, IBMOptions{ContentType: contentType})
+	if err != nil {
+		return nil, &TranscribeError{Path: name, Err: errors.Trace(err)}
+	}
+	return res, nil
+}
+
+// stdin is the reader of TranscribeStdin.
+var stdin io.Reader = os.Stdin
+
+// TranscribeStdin transcribes audio of the given content type, such as
+// audio/flac, read from standard input.
+func TranscribeStdin(ctx context.Context, contentType string, creds IBMCredentials) (*IBMResult, error) {
+	res, err := TranscribeReaderWithIBM(ctx, stdin, nil, creds, IBMOptions{ContentType: contentType})
+	if err != nil {
+		return nil, tracePhase(err)
+	}
+	return res, nil
+}
+
+// TranscribeFirstPhrase transcribes FLAC audio read from r until IBM sends its
+// first final result, and returns the transcript of that phrase. The
+// connection to IBM is closed as soon as the phrase arrives, without waiting
+// for the rest of the audio, so r can be a live stream such as a microphone.
+// Nothing more is uploaded after the phrase, but a read from r which is in
+// progress is not interrupted. It returns a *TranscribeError if transcription
+// fails.
+func TranscribeFirstPhrase(ctx context.Context, r io.Reader, creds IBMCredentials) (string, error) {
+	phrase, err := transcribeFirstPhrase(ctx, r, creds)
+	if err != nil {
+		return "", &TranscribeError{Err: errors.Trace(err)}
+	}
+	return phrase, nil
+}
+
+// transcribeFirstPhrase transcribes the first phrase for TranscribeFirstPhrase.
+func transcribeFirstPhrase(ctx context.Context, r io.Reader, creds IBMCredentials) (string, error) {
+	opts := IBMOptions{}
+	requestArgs, err := opts.startMessage(nil)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	url := creds.websocketURL() + "?" + opts.query().Encode()
+	ws, _, err := opts.dialer().Dial(url, ibmHeader(creds))
+	if err != nil {
+		return "", errors.Trace(err)
 	}
 	defer ws.Close()
 
-	requestArgs := map[string]interface{}{
-		"action":             "start",
-		"content-type":       "audio/flac",
-		"continuous":         true,
-		"word_confidence":    true,
-		"timestamps":         true,
-		"profanity_filter":   false,
-		"interim_results":    false,
-		"inactivity_timeout": -1,
-		"keywords":           searchWords,
-		"keywords_threshold": 0.5,
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			ws.Close()
+		case <-done:
+		}
+	}()
+
+	if err := ws.WriteJSON(requestArgs); err != nil {
+		return "", errors.Trace(err)
 	}
+	// results are read while uploading, and the upload fails once the
+	// connection is closed
+	go func() {
+		if err := uploadWithWebsocket(ws, r, opts.frameSize()); err == nil {
+			ws.WriteMessage(websocket.BinaryMessage, []byte{})
+		}
+	}()
 
-	if err = ws.WriteJSON(requestArgs); err != nil {
+	phrase, err := readFirstPhrase(ws)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", errors.Trace(ctx.Err())
+		}
+		return "", errors.Trace(err)
+	}
+	return phrase, nil
+}
+
+// transcribeWithIBM transcribes the audio returned by open, reconnecting if
+// the connection to IBM closes abnormally. open is called for every
+// connection.
+func transcribeWithIBM(ctx context.Context, open func() (io.ReadCloser, error), searchWords []string, creds IBMCredentials, opts IBMOptions) (*IBMResult, error) {
+	requestArgs, err := opts.startMessage(searchWords)
+	if err != nil {
 		return nil, errors.Trace(err)
 	}
+
+	url := creds.websocketURL() + "?" + opts.query().Encode()
+	header := ibmHeader(creds)
+
+	results := newResultAccumulator()
+	if opts.IncrementalOutputPath != "" {
+		output, err := os.Create(opts.IncrementalOutputPath)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		defer output.Close()
+		results.output = output
+	}
+	if opts.RawOutputPath != "" {
+		raw, err := createRawOutput(opts)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		defer raw.Close()
+		results.raw = raw
+	}
+	if opts.WebhookURL != "" {
+		webhook := newWebhookPoster(ctx, opts.WebhookURL)
+		defer webhook.close()
+		results.onFinal = webhook.post
+	}
+	attempt := 0
+	for ; ; attempt++ {
+		audio, err := open()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		err = recognizeWithIBM(ctx, url, header, requestArgs, audio, results, opts)
+		audio.Close()
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return nil, errors.Trace(ctx.Err())
+		}
+		if !websocket.IsCloseError(errors.Cause(err), websocket.CloseAbnormalClosure) {
+			return nil, errors.Trace(err)
+		}
+		if attempt == ibmReconnectAttempts {
+			return nil, errors.Annotatef(err, "gave up after %d reconnects to IBM", attempt)
+		}
+		log.Warnf("Connection to IBM closed abnormally, reconnecting: %v", err)
+		if opts.OnRetry != nil {
+			opts.OnRetry(attempt+1, err)
+		}
+		results.resume()
+	}
+	log.Debugf("IBM has returned results")
+	if opts.OnSuccess != nil {
+		opts.OnSuccess(attempt + 1)
+	}
+	res := results.result()
+	res.Model = opts.model()
+	return res, nil
+}
+
+// ibmReconnectAttempts is how many times TranscribeWithIBM reconnects after
+// the connection to IBM closes abnormally.
+var ibmReconnectAttempts = 3
+
+// recognizeWithIBM uploads audio over a new connection to IBM and reads the
+// results into results. The connection is closed if ctx is done.
+func recognizeWithIBM(ctx context.Context, url string, header http.Header, requestArgs map[string]interface{}, audio io.Reader, results *resultAccumulator, opts IBMOptions) error {
+	ws, _, err := opts.dialer().Dial(url, header)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer ws.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			ws.Close()
+		case <-done:
+		}
+	}()
+
+	if err = ws.WriteJSON(requestArgs); err != nil {
+		return errors.Trace(err)
+	}
 	log.Debug("Starting transcription using IBM")
 
-	if err = uploadFileWithWebsocket(ws, filePath); err != nil {
-		return nil, errors.Trace(err)
+	if err = uploadWithWebsocket(ws, audio, opts.frameSize()); err != nil {
+		return errors.Trace(err)
 	}
-	log.Debugf("Successfully uploaded %s to IBM", filePath)
+	log.Debug("Successfully uploaded audio to IBM")
 
-	// write empty message to indicate end of uploading file
+	// write empty message to indicate end of uploading file. It must be the
+	// last binary message, which holds since keepalives are text messages.
 	if err = ws.WriteMessage(websocket.BinaryMessage, []byte{}); err != nil {
-		return nil, errors.Trace(err)
+		return errors.Trace(err)
 	}
 
 	// IBM must receive a message every 30 seconds or it will close the websocket.
-	// This code concurrently writes a message every 5 second until returning.
-	ticker := time.NewTicker(5 * time.Second)
+	// This code concurrently writes a message every keepaliveInterval until
+	// returning.
+	ticker := time.NewTicker(keepaliveInterval)
 	quit := make(chan struct{})
-	go keepConnectionOpen(ws, ticker, quit)
+	keepaliveErr := make(chan error, 1)
+	go keepConnectionOpen(ws, ticker, quit, keepaliveErr)
 	defer close(quit)
 
-	for {
-		err := ws.ReadJSON(&result)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		if len(result.Results) > 0 {
-			log.Debugf("IBM has returned results")
-			return result, nil
+	var reader jsonReader = ws
+	if results.raw != nil {
+		reader = &rawRecorder{ws: ws, raw: results.raw}
+	}
+	if err := readResults(reader, results, opts); err != nil {
+		select {
+		case err := <-keepaliveErr:
+			return keepaliveFailure(err)
+		default:
 		}
+		return errors.Trace(err)
 	}
+	return nil
+}
+
+// ibmHeader returns the header of a connection to IBM.
+func ibmHeader(creds IBMCredentials) http.Header {
+	header := http.Header{}
+	header.Set("User-Agent", UserAgent)
+	header.Set("Authorization", "Basic "+basicAuth(creds.Username, creds.Password))
+	return header
 }
 
 func basicAuth(username, password string) string {
@@ -110,48 +377,135 @@ func basicAuth(username, password string) string {
 	return base64.StdEncoding.EncodeToString([]byte(auth))
 }
 
-func uploadFileWithWebsocket(ws *websocket.Conn, filePath string) error {
-	f, err := os.Open(filePath)
-	if err != nil {
-		return errors.Trace(err)
+// uploadWithWebsocket sends the audio read from r to IBM in binary messages of
+// frameSize bytes. Only the last message may be shorter.
+func uploadWithWebsocket(ws *websocket.Conn, r io.Reader, frameSize int) error {
+	buffer := make([]byte, frameSize)
+	for {
+		n, err := io.ReadFull(r, buffer)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		if n > 0 {
+			if err := ws.WriteMessage(websocket.BinaryMessage, buffer[:n]); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Trace(err)
+		}
 	}
+}
+
+// followPollInterval is how often a followReader checks whether its file has
+// grown.
+var followPollInterval = 100 * time.Millisecond
 
-	r := bufio.NewReader(f)
-	buffer := make([]byte, 2048)
+// followReader reads a file which is still being written. At the end of the
+// file, it waits for more to be written, and only returns io.EOF once nothing
+// was written for timeout.
+type followReader struct {
+	file     *os.File
+	timeout  time.Duration
+	lastRead time.Time
+}
 
+func (r *followReader) Read(p []byte) (int, error) {
 	for {
-		n, err := r.Read(buffer)
-		if n == 0 {
-			break
+		n, err := r.file.Read(p)
+		if n > 0 {
+			r.lastRead = time.Now()
+			return n, nil
 		}
-		if err != nil && err != io.EOF {
-			return errors.Trace(err)
+		if err != io.EOF {
+			return 0, err
 		}
-		if err := ws.WriteMessage(websocket.BinaryMessage, buffer); err != nil {
-			return errors.Trace(err)
+		if time.Since(r.lastRead) >= r.timeout {
+			return 0, io.EOF
 		}
+		time.Sleep(followPollInterval)
 	}
-	return nil
 }
 
-func keepConnectionOpen(ws *websocket.Conn, ticker *time.Ticker, quit chan struct{}) {
+func (r *followReader) Close() error {
+	return r.file.Close()
+}
+
+var (
+	// keepaliveInterval is how often a keepalive is sent to IBM while it
+	// transcribes.
+	keepaliveInterval = 5 * time.Second
+	// keepaliveRetries is how many times a failed keepalive is retried before
+	// the connection to IBM is given up.
+	keepaliveRetries = 3
+	// keepaliveRetryDelay is the delay before the first retry of a failed
+	// keepalive. It doubles with every retry.
+	keepaliveRetryDelay = 100 * time.Millisecond
+)
+
+// keepaliveConn is the part of a websocket.Conn used by keepConnectionOpen.
+type keepaliveConn interface {
+	WriteJSON(v interface{}) error
+	SetReadDeadline(t time.Time) error
+}
+
+// keepConnectionOpen writes a keepalive message to ws on every tick until quit
+// is closed. A failed keepalive is retried keepaliveRetries times with
+// exponential backoff. If every retry fails, the error is sent on failed and
+// the read deadline of ws is set so that the reader stops waiting, which
+// makes transcribeWithIBM reconnect. Note that a websocket.Conn keeps failing
+// with its first write error, so on a real connection the retries only help
+// if the write failed before anything was sent.
+func keepConnectionOpen(ws keepaliveConn, ticker *time.Ticker, quit chan struct{}, failed chan<- error) {
+	defer ticker.Stop()
 	for {
 		select {
 		case <-ticker.C:
-			err := ws.WriteJSON(map[string]string{
-				"action": "no-op",
-			})
-			if err != nil {
+			if err := writeKeepalive(ws, quit); err != nil {
+				failed <- errors.Trace(err)
+				ws.SetReadDeadline(time.Now())
 				return
 			}
 		case <-quit:
-			ticker.Stop()
 			return
 		}
 	}
 }
 
-// GetTranscription gets the full transcript from an IBMResult.
+// writeKeepalive writes a no-op message to ws, retrying a failed write
+// keepaliveRetries times unless quit is closed meanwhile.
+func writeKeepalive(ws keepaliveConn, quit chan struct{}) error {
+	delay := keepaliveRetryDelay
+	for retry := 0; ; retry++ {
+		err := ws.WriteJSON(map[string]string{
+			"action": "no-op",
+		})
+		if err == nil || retry == keepaliveRetries {
+			return errors.Trace(err)
+		}
+		log.Warnf("Could not send keepalive to IBM, retrying in %v: %v", delay, err)
+		select {
+		case <-time.After(delay):
+		case <-quit:
+			return nil
+		}
+		delay *= 2
+	}
+}
+
+// keepaliveFailure returns the error of a connection whose keepalive failed
+// with err. Its cause is an abnormal closure, so that transcribeWithIBM
+// reconnects as if the connection had dropped.
+func keepaliveFailure(err error) error {
+	closed := &websocket.CloseError{Code: websocket.CloseAbnormalClosure, Text: err.Error()}
+	return errors.Wrapf(err, closed, "could not keep connection to IBM open")
+}
+
+// GetTranscription gets the full transcript from the final results of
+// IBMResults. The transcript is normalized with NormalizeText.
 func GetTranscription(results []*IBMResult) *Transcription {
 	timestamps := []timestamp{}
 	confidences := []confidence{}
@@ -159,7 +513,7 @@ func GetTranscription(results []*IBMResult) *Transcription {
 
 	var transcriptBuffer bytes.Buffer
 	for _, result := range results {
-		for _, subResult := range result.Results {
+		for _, subResult := range FinalResults(result) {
 			bestHypothesis := subResult.Alternatives[0]
 			transcriptBuffer.WriteString(bestHypothesis.Transcript)
 			for _, ibmTimestamp := range bestHypothesis.Timestamps {
@@ -182,7 +536,7 @@ func GetTranscription(results []*IBMResult) *Transcription {
 	}
 
 	transcription := &Transcription{
-		Transcript:  transcriptBuffer.String(),
+		Transcript:  NormalizeText(transcriptBuffer.String()),
 		CompletedAt: time.Now(),
 		Timestamps:  timestamps,
 		Confidences: confidences,
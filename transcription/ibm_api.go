@@ -0,0 +1,122 @@
+package transcription
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+const defaultIBMURL = "https://stream.watsonplatform.net/speech-to-text/api"
+
+// IBMCredentials are the credentials of an IBM Speech To Text service.
+type IBMCredentials struct {
+	Username string
+	Password string
+	// URL is the url of the service. Defaults to
+	// https://stream.watsonplatform.net/speech-to-text/api.
+	URL string
+}
+
+// url returns the url of the service, without a trailing slash.
+func (creds IBMCredentials) url() string {
+	if creds.URL != "" {
+		return strings.TrimRight(creds.URL, "/")
+	}
+	return defaultIBMURL
+}
+
+// websocketURL returns the url of the websocket recognize endpoint.
+func (creds IBMCredentials) websocketURL() string {
+	url := creds.url()
+	if strings.HasPrefix(url, "https://") {
+		url = "wss://" + strings.TrimPrefix(url, "https://")
+	} else if strings.HasPrefix(url, "http://") {
+		url = "ws://" + strings.TrimPrefix(url, "http://")
+	}
+	return url + "/v1/recognize"
+}
+
+// IBMAuthError is returned when IBM rejects the credentials.
+type IBMAuthError struct {
+	Status string
+}
+
+func (e *IBMAuthError) Error() string {
+	return "IBM rejected the credentials: " + e.Status
+}
+
+// IBMConnectionError is returned when IBM cannot be reached or does not
+// respond successfully.
+type IBMConnectionError struct {
+	Err error
+}
+
+func (e *IBMConnectionError) Error() string {
+	return "could not connect to IBM: " + e.Err.Error()
+}
+
+// PingIBM checks that the IBM service is reachable with the given credentials
+// by requesting the list of models. It returns an *IBMAuthError if the
+// credentials are rejected and an *IBMConnectionError for any other failure.
+func PingIBM(ctx context.Context, creds IBMCredentials) error {
+	response, err := getIBM(ctx, creds, "/v1/models")
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+// IBMModel describes a model of the IBM service.
+type IBMModel struct {
+	Name        string `json:"name"`
+	Language    string `json:"language"`
+	Rate        int    `json:"rate"`
+	Description string `json:"description"`
+}
+
+// ListIBMModels returns the models available from the IBM service.
+func ListIBMModels(ctx context.Context, creds IBMCredentials) ([]IBMModel, error) {
+	response, err := getIBM(ctx, creds, "/v1/models")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var models struct {
+		Models []IBMModel `json:"models"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&models); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return models.Models, nil
+}
+
+// getIBM sends an authenticated GET request to path of the IBM service. The
+// errors are the same as those of PingIBM.
+func getIBM(ctx context.Context, creds IBMCredentials, path string) (*http.Response, error) {
+	request, err := http.NewRequest("GET", creds.url()+path, nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("User-Agent", UserAgent)
+	request.SetBasicAuth(creds.Username, creds.Password)
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, &IBMConnectionError{Err: err}
+	}
+	switch {
+	case response.StatusCode == http.StatusUnauthorized:
+		response.Body.Close()
+		return nil, &IBMAuthError{Status: response.Status}
+	case response.StatusCode != http.StatusOK:
+		response.Body.Close()
+		return nil, &IBMConnectionError{Err: errors.Errorf("unexpected status %s", response.Status)}
+	}
+	return response, nil
+}
@@ -0,0 +1,98 @@
+package transcription
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newMockIBMAPI returns a server which responds to authenticated requests to
+// path with body.
+func newMockIBMAPI(path string, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		if username != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != path {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+}
+
+func TestPingIBM(t *testing.T) {
+	assert := assert.New(t)
+	server := newMockIBMAPI("/v1/models", `{"models": []}`)
+	defer server.Close()
+
+	err := PingIBM(context.Background(), IBMCredentials{Username: "user", Password: "pass", URL: server.URL})
+	assert.NoError(err)
+}
+
+func TestPingIBMReturnsAuthError(t *testing.T) {
+	assert := assert.New(t)
+	server := newMockIBMAPI("/v1/models", `{"models": []}`)
+	defer server.Close()
+
+	err := PingIBM(context.Background(), IBMCredentials{Username: "user", Password: "wrong", URL: server.URL})
+	assert.IsType(&IBMAuthError{}, err)
+}
+
+func TestPingIBMReturnsConnectionError(t *testing.T) {
+	assert := assert.New(t)
+	server := newMockIBMAPI("/v1/models", `{"models": []}`)
+	url := server.URL
+	server.Close()
+
+	err := PingIBM(context.Background(), IBMCredentials{Username: "user", Password: "pass", URL: url})
+	assert.IsType(&IBMConnectionError{}, err)
+}
+
+func TestIBMCredentialsWebsocketURL(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("wss://stream.watsonplatform.net/speech-to-text/api/v1/recognize", IBMCredentials{}.websocketURL())
+	assert.Equal("ws://127.0.0.1:80/v1/recognize", IBMCredentials{URL: "http://127.0.0.1:80/"}.websocketURL())
+}
+
+func TestListIBMModels(t *testing.T) {
+	assert := assert.New(t)
+	server := newMockIBMAPI("/v1/models", `{
+		"models": [
+			{
+				"name": "en-US_BroadbandModel",
+				"language": "en-US",
+				"rate": 16000,
+				"url": "https://stream.watsonplatform.net/speech-to-text/api/v1/models/en-US_BroadbandModel",
+				"description": "US English broadband model."
+			},
+			{
+				"name": "en-US_NarrowbandModel",
+				"language": "en-US",
+				"rate": 8000,
+				"description": "US English narrowband model."
+			},
+			{
+				"name": "fr-FR_BroadbandModel",
+				"language": "fr-FR",
+				"rate": 16000,
+				"description": "French broadband model."
+			}
+		]
+	}`)
+	defer server.Close()
+
+	models, err := ListIBMModels(context.Background(), IBMCredentials{Username: "user", Password: "pass", URL: server.URL})
+	assert.NoError(err)
+	assert.Equal([]IBMModel{
+		{Name: "en-US_BroadbandModel", Language: "en-US", Rate: 16000, Description: "US English broadband model."},
+		{Name: "en-US_NarrowbandModel", Language: "en-US", Rate: 8000, Description: "US English narrowband model."},
+		{Name: "fr-FR_BroadbandModel", Language: "fr-FR", Rate: 16000, Description: "French broadband model."},
+	}, models)
+}
@@ -0,0 +1,37 @@
+package transcription
+
+import (
+	"context"
+	"embed"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//go:embed test.flac
+var testAudioFS embed.FS
+
+func TestTranscribeFileWithIBMReadsEmbeddedFile(t *testing.T) {
+	assert := assert.New(t)
+
+	server, requests := newRecordingIBMServer(t)
+	defer server.Close()
+
+	f, err := testAudioFS.Open("test.flac")
+	if !assert.NoError(err) {
+		return
+	}
+	defer f.Close()
+
+	creds := IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	res, err := TranscribeFileWithIBM(context.Background(), f, "audio/flac", creds)
+	assert.NoError(err)
+	assert.Equal([]string{"hello "}, transcripts(res))
+
+	audio, err := ioutil.ReadFile("test.flac")
+	assert.NoError(err)
+	request := <-requests
+	assert.Equal("audio/flac", request.Start["content-type"])
+	assert.Equal(audio, request.Audio)
+}
@@ -0,0 +1,164 @@
+package transcription
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hack4impact/transcribe4all/transcription/ibmtest"
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockKeepaliveConn fails the writes whose index is in failures.
+type mockKeepaliveConn struct {
+	sync.Mutex
+	failures     map[int]bool
+	writes       int
+	deadlineSet  bool
+	successfulCh chan struct{}
+	// messages are the JSON of the successful writes.
+	messages []string
+}
+
+func (c *mockKeepaliveConn) WriteJSON(v interface{}) error {
+	data, _ := json.Marshal(v)
+	return c.write(string(data))
+}
+
+func (c *mockKeepaliveConn) write(message string) error {
+	c.Lock()
+	defer c.Unlock()
+	write := c.writes
+	c.writes++
+	if c.failures[write] {
+		return errors.New("write failed")
+	}
+	c.messages = append(c.messages, message)
+	c.successfulCh <- struct{}{}
+	return nil
+}
+
+func (c *mockKeepaliveConn) SetReadDeadline(t time.Time) error {
+	c.Lock()
+	c.deadlineSet = true
+	c.Unlock()
+	return nil
+}
+
+// stubKeepaliveRetries makes failed keepalives retry quickly.
+func stubKeepaliveRetries() (restore func()) {
+	old := keepaliveRetryDelay
+	keepaliveRetryDelay = time.Millisecond
+	return func() { keepaliveRetryDelay = old }
+}
+
+func TestKeepConnectionOpenRetriesFailedWrite(t *testing.T) {
+	assert := assert.New(t)
+	defer stubKeepaliveRetries()()
+
+	ws := &mockKeepaliveConn{failures: map[int]bool{1: true}, successfulCh: make(chan struct{}, 10)}
+	quit := make(chan struct{})
+	failed := make(chan error, 1)
+	go keepConnectionOpen(ws, time.NewTicker(10*time.Millisecond), quit, failed)
+
+	// the write after the failed one succeeds and keepalives go on
+	for i := 0; i < 3; i++ {
+		select {
+		case <-ws.successfulCh:
+		case <-time.After(time.Second):
+			t.Fatal("keepalives stopped after a failed write")
+		}
+	}
+	close(quit)
+
+	select {
+	case err := <-failed:
+		t.Fatalf("keepalive failure was signaled: %v", err)
+	default:
+	}
+	ws.Lock()
+	defer ws.Unlock()
+	assert.False(ws.deadlineSet)
+}
+
+func TestKeepConnectionOpenSignalsFailedRetries(t *testing.T) {
+	assert := assert.New(t)
+	defer stubKeepaliveRetries()()
+
+	// every write after the first fails
+	failures := map[int]bool{}
+	for i := 1; i <= 10; i++ {
+		failures[i] = true
+	}
+	ws := &mockKeepaliveConn{failures: failures, successfulCh: make(chan struct{}, 10)}
+	quit := make(chan struct{})
+	defer close(quit)
+	failed := make(chan error, 1)
+	go keepConnectionOpen(ws, time.NewTicker(time.Millisecond), quit, failed)
+
+	select {
+	case err := <-failed:
+		assert.Error(err)
+	case <-time.After(time.Second):
+		t.Fatal("keepalive failure was not signaled")
+	}
+
+	// the failed write is retried keepaliveRetries times
+	time.Sleep(10 * time.Millisecond)
+	ws.Lock()
+	defer ws.Unlock()
+	assert.Equal(2+keepaliveRetries, ws.writes)
+	assert.True(ws.deadlineSet)
+}
+
+func TestKeepaliveFailureReconnects(t *testing.T) {
+	assert := assert.New(t)
+
+	err := keepaliveFailure(errors.New("broken pipe"))
+	assert.True(websocket.IsCloseError(errors.Cause(err), websocket.CloseAbnormalClosure))
+	assert.Contains(err.Error(), "broken pipe")
+}
+
+func TestKeepConnectionOpenWritesNoOp(t *testing.T) {
+	assert := assert.New(t)
+
+	ws := &mockKeepaliveConn{successfulCh: make(chan struct{}, 10)}
+	quit := make(chan struct{})
+	failed := make(chan error, 1)
+	go keepConnectionOpen(ws, time.NewTicker(time.Millisecond), quit, failed)
+
+	select {
+	case <-ws.successfulCh:
+	case <-time.After(time.Second):
+		t.Fatal("no keepalive was written")
+	}
+	close(quit)
+
+	ws.Lock()
+	assert.Equal(`{"action":"no-op"}`, ws.messages[0])
+	ws.Unlock()
+}
+
+func TestKeepalivesAreAcceptedByMockIBMServer(t *testing.T) {
+	assert := assert.New(t)
+	defer func(interval time.Duration) { keepaliveInterval = interval }(keepaliveInterval)
+	keepaliveInterval = time.Millisecond
+
+	// the mock fails the test if a keepalive is sent as audio after the
+	// end of the upload
+	server := ibmtest.NewMockIBMServer(t, []ibmtest.MockFrame{
+		{JSON: `{"state": "listening"}`},
+		{JSON: `{"results": [{"alternatives": [{"transcript": "hello "}], "final": true}], "result_index": 0}`, Delay: 50 * time.Millisecond},
+		{JSON: `{"state": "listening"}`},
+	})
+	defer server.Close()
+
+	res, err := TranscribeWithIBMContext(context.Background(), "test.flac", nil, IBMCredentials{Username: "user", Password: "pass", URL: server.URL}, IBMOptions{})
+	if assert.NoError(err) {
+		assert.Equal("hello", GetTranscription([]*IBMResult{res}).Transcript)
+	}
+}
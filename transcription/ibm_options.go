@@ -0,0 +1,356 @@
+package transcription
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/websocket"
+	"github.com/juju/errors"
+)
+
+const (
+	defaultIBMModel    = "en-US_BroadbandModel"
+	narrowbandIBMModel = "en-US_NarrowbandModel"
+	// ibmMaxFrameSize is the largest websocket message IBM accepts.
+	ibmMaxFrameSize = 4 << 20
+)
+
+// IBMOptions contains optional parameters for a transcription with IBM. The
+// zero value uses IBM's defaults.
+type IBMOptions struct {
+	// Model is the IBM recognition model. Defaults to en-US_BroadbandModel.
+	Model string
+	// ContentType is the format of the audio, such as audio/wav. Defaults to
+	// audio/flac.
+	ContentType string
+	// SampleRate is the sample rate of raw audio in Hz, such as 16000. IBM
+	// requires it for audio/l16 and audio/mulaw, which have no header, and it
+	// is added to their ContentType, such as audio/l16;rate=16000.
+	SampleRate int
+	// FrameSize is the most bytes of audio sent to IBM in one websocket frame.
+	// Defaults to 2048. Larger frames upload large files faster, but a stream
+	// waits until a whole frame is read. It is capped at MaxFrameSize.
+	FrameSize int
+	// MaxFrameSize is the most bytes sent in one websocket frame, whatever
+	// FrameSize is, such as for a proxy with a lower limit. It must not exceed
+	// IBM's limit of 4MB, which is the default.
+	MaxFrameSize int
+	// Follow keeps reading the audio file of TranscribeWithIBM after its end,
+	// like tail -f, so that a file which is still being written is streamed
+	// to IBM as it grows. The audio ends once the file has not grown for
+	// FollowTimeout.
+	Follow bool
+	// FollowTimeout is how long Follow waits for the file to grow before the
+	// audio ends. Defaults to 10 seconds.
+	FollowTimeout time.Duration
+	// LoudnessTarget normalizes the audio file of TranscribeWithIBM to this
+	// integrated loudness, in LUFS, with NormalizeLoudness before it is sent to
+	// IBM, which helps with quiet recordings. It must be between -70 and -5,
+	// and -16 is common for speech. Zero leaves the audio as it is. It cannot
+	// be used with Follow.
+	LoudnessTarget float64
+	// AutoSelectModel chooses en-US_NarrowbandModel for audio sampled at 8kHz
+	// or less and en-US_BroadbandModel otherwise. It has no effect if Model is
+	// set.
+	AutoSelectModel bool
+	// CustomizationID is the ID of a custom language model to use.
+	CustomizationID string
+	// GrammarName is the name of a grammar of the custom language model which
+	// constrains recognition to the phrases it describes. It requires
+	// CustomizationID.
+	GrammarName string
+	// SpeakerLabels enables the labeling of which speaker said each word.
+	SpeakerLabels bool
+	// SplitTranscriptAtPhraseEnd splits results at the end of phrases, such as
+	// at pauses, rather than at arbitrary points.
+	SplitTranscriptAtPhraseEnd bool
+	// LowLatency trades some accuracy for getting results sooner. Only the
+	// next-generation Telephony and Multimedia models support it.
+	LowLatency bool
+	// SmartFormatting makes IBM write dates, times, numbers, currency and
+	// similar phrases in their conventional form, such as "$5" for "five
+	// dollars".
+	SmartFormatting bool
+	// Redaction makes IBM replace every number of three or more digits in the
+	// transcript, such as a credit card or phone number, with an X. It
+	// requires SmartFormatting. Redaction is only available for US English.
+	Redaction bool
+	// OmitDeprecatedParameters leaves parameters which IBM has deprecated, such
+	// as continuous, out of the start message. Newer models warn about them.
+	OmitDeprecatedParameters bool
+	// EndOfPhraseSilenceTime is the length, in seconds, of a pause at which IBM
+	// ends a phrase and starts a new result, such as Float64(1.5). It must be
+	// between 0 and 120. Nil uses IBM's default of 0.8 seconds.
+	EndOfPhraseSilenceTime *float64
+	// CharacterInsertionBias makes IBM prefer shorter words, if it is
+	// negative, or longer words, if it is positive. It must be between -1 and
+	// 1. Zero uses IBM's default of no bias. Only next-generation models
+	// support it.
+	CharacterInsertionBias float64
+	// SpeechDetectorSensitivity is how readily IBM treats audio as speech, from
+	// 0 to 1, such as Float64(0.2). Lower values ignore more noise. Nil uses
+	// IBM's default of 0.5.
+	SpeechDetectorSensitivity *float64
+	// BackgroundAudioSuppression is how strongly IBM suppresses background
+	// audio such as noise and side conversations, from 0 to 1. Nil uses IBM's
+	// default of no suppression.
+	BackgroundAudioSuppression *float64
+	// OnProgress enables IBM's processing metrics and is called with the
+	// percentage of the audio which IBM has transcribed so far.
+	OnProgress func(percent float64)
+	// OnWarning is called with every warning of IBM, such as about an unknown
+	// parameter, instead of logging it. Set it to a function which does
+	// nothing to ignore warnings. They are kept in the Warnings of the result
+	// either way.
+	OnWarning func(warning string)
+	// ProcessingMetricsInterval is how often, in seconds, IBM sends processing
+	// metrics. IBM defaults to 1 second.
+	ProcessingMetricsInterval float64
+	// MaxResults limits how many results are kept in memory. Transcription
+	// fails if IBM sends more results. Zero means no limit.
+	MaxResults int
+	// IncrementalOutputPath is the path of a file to which the text of each
+	// final result is written, one line per result, as soon as it arrives. If
+	// transcription fails, the file contains the transcript so far.
+	IncrementalOutputPath string
+	// RawOutputPath is the path of a file to which every message of IBM is
+	// written as newline delimited JSON, such as to debug a transcription or
+	// to parse it again with ReadRawOutput.
+	RawOutputPath string
+	// GzipRawOutput compresses the file at RawOutputPath with gzip and appends
+	// .gz to its path, such as transcript.ndjson.gz.
+	GzipRawOutput bool
+	// WebhookURL is a url to which each final result is POSTed as soon as it
+	// arrives, as JSON in the format of IBM with a single result. Results are
+	// posted in order from another goroutine, so a slow webhook does not hold
+	// up the transcription, and are all posted before it returns. Failed posts
+	// are retried, and are then logged without failing the transcription.
+	WebhookURL string
+	// HandshakeTimeout is how long to wait for the websocket handshake with
+	// IBM. Zero means no timeout.
+	HandshakeTimeout time.Duration
+	// InsecureSkipVerify accepts any TLS certificate of IBM's url, such as a
+	// self-signed one of a test server. It is unsafe, since anyone on the
+	// network can then read the audio and transcript, and must only be used
+	// for testing.
+	InsecureSkipVerify bool
+	// Proxy returns the proxy to connect to IBM through. Defaults to the proxy
+	// given to SetProxy or, if there is none, the proxy of the environment.
+	Proxy func(*http.Request) (*url.URL, error)
+	// OnRetry is called with the number of the failed attempt, starting at 1,
+	// and its error before reconnecting after the connection to IBM closes
+	// abnormally, such as to count reconnects in metrics.
+	OnRetry func(attempt int, err error)
+	// OnSuccess is called with the number of attempts, including reconnects,
+	// once IBM has returned every result.
+	OnSuccess func(attempts int)
+}
+
+// Float64 returns a pointer to v, for the options of IBMOptions which tell an
+// explicit zero from an unset value.
+func Float64(v float64) *float64 {
+	return &v
+}
+
+// validate returns an error if the options cannot be sent to IBM.
+func (opts IBMOptions) validate() error {
+	if opts.GrammarName != "" && opts.CustomizationID == "" {
+		return errors.New("grammar name requires a customization id")
+	}
+	if opts.LowLatency && !supportsLowLatency(opts.model()) {
+		return errors.Errorf("model %s does not support low latency", opts.model())
+	}
+	if opts.LoudnessTarget != 0 && opts.Follow {
+		return errors.New("cannot normalize the loudness of a file which is still being written")
+	}
+	if opts.MaxFrameSize < 0 || opts.MaxFrameSize > ibmMaxFrameSize {
+		return errors.Errorf("max frame size must be between 0 and %d bytes, got %d", ibmMaxFrameSize, opts.MaxFrameSize)
+	}
+	if isRawAudio(opts.ContentType) && opts.SampleRate <= 0 && !strings.Contains(opts.ContentType, "rate=") {
+		return errors.Errorf("content type %s requires a sample rate", opts.ContentType)
+	}
+	if opts.Redaction && !opts.SmartFormatting {
+		return errors.New("redaction requires smart formatting")
+	}
+	if v := opts.EndOfPhraseSilenceTime; v != nil && (*v < 0 || *v > 120) {
+		return errors.Errorf("end of phrase silence time must be between 0 and 120 seconds, got %v", *v)
+	}
+	if opts.CharacterInsertionBias < -1 || opts.CharacterInsertionBias > 1 {
+		return errors.Errorf("character insertion bias must be between -1 and 1, got %v", opts.CharacterInsertionBias)
+	}
+	if v := opts.SpeechDetectorSensitivity; v != nil && (*v < 0 || *v > 1) {
+		return errors.Errorf("speech detector sensitivity must be between 0 and 1, got %v", *v)
+	}
+	if v := opts.BackgroundAudioSuppression; v != nil && (*v < 0 || *v > 1) {
+		return errors.Errorf("background audio suppression must be between 0 and 1, got %v", *v)
+	}
+	return nil
+}
+
+// supportsLowLatency returns whether IBM's model supports low latency, which
+// is true for the next-generation models such as en-US_Telephony.
+func supportsLowLatency(model string) bool {
+	return strings.HasSuffix(model, "_Telephony") || strings.HasSuffix(model, "_Multimedia")
+}
+
+// model returns the model to use for recognition.
+func (opts IBMOptions) model() string {
+	if opts.Model != "" {
+		return opts.Model
+	}
+	return defaultIBMModel
+}
+
+// contentType returns the format of the audio.
+func (opts IBMOptions) contentType() string {
+	if opts.ContentType == "" {
+		return "audio/flac"
+	}
+	if isRawAudio(opts.ContentType) && opts.SampleRate > 0 && !strings.Contains(opts.ContentType, "rate=") {
+		return opts.ContentType + ";rate=" + strconv.Itoa(opts.SampleRate)
+	}
+	return opts.ContentType
+}
+
+// isRawAudio returns whether contentType is a format of raw audio, whose
+// sample rate IBM cannot tell from the audio.
+func isRawAudio(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	return mediaType == "audio/l16" || mediaType == "audio/mulaw"
+}
+
+// frameSize returns the most bytes of audio sent in one frame.
+func (opts IBMOptions) frameSize() int {
+	size := 2048
+	if opts.FrameSize > 0 {
+		size = opts.FrameSize
+	}
+	if limit := opts.maxFrameSize(); size > limit {
+		return limit
+	}
+	return size
+}
+
+// maxFrameSize returns the most bytes sent in one frame.
+func (opts IBMOptions) maxFrameSize() int {
+	if opts.MaxFrameSize > 0 && opts.MaxFrameSize < ibmMaxFrameSize {
+		return opts.MaxFrameSize
+	}
+	return ibmMaxFrameSize
+}
+
+// followTimeout returns how long Follow waits for the file to grow.
+func (opts IBMOptions) followTimeout() time.Duration {
+	if opts.FollowTimeout > 0 {
+		return opts.FollowTimeout
+	}
+	return 10 * time.Second
+}
+
+// withModelForFile returns the options with Model set according to the
+// sample rate of the file at filePath, if AutoSelectModel is set. If the
+// file's sample rate cannot be determined, the default model is used.
+func (opts IBMOptions) withModelForFile(filePath string) IBMOptions {
+	if !opts.AutoSelectModel || opts.Model != "" {
+		return opts
+	}
+	info, err := GetAudioInfo(filePath)
+	if err != nil {
+		log.Debugf("Using default model since the sample rate of %s is unknown: %v", filePath, err)
+		return opts
+	}
+	if info.SampleRate <= 8000 {
+		opts.Model = narrowbandIBMModel
+	} else {
+		opts.Model = defaultIBMModel
+	}
+	return opts
+}
+
+// dialer returns the dialer used to connect to IBM.
+func (opts IBMOptions) dialer() *websocket.Dialer {
+	dialer := *websocket.DefaultDialer
+	dialer.HandshakeTimeout = opts.HandshakeTimeout
+	if opts.InsecureSkipVerify {
+		dialer.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	dialer.Proxy = currentProxy
+	if opts.Proxy != nil {
+		dialer.Proxy = opts.Proxy
+	}
+	return &dialer
+}
+
+// query returns the query parameters of the recognize url.
+func (opts IBMOptions) query() url.Values {
+	query := url.Values{}
+	query.Set("model", opts.model())
+	if opts.CustomizationID != "" {
+		query.Set("customization_id", opts.CustomizationID)
+	}
+	return query
+}
+
+// startMessage returns the message which starts a recognition request.
+func (opts IBMOptions) startMessage(searchWords []string) (map[string]interface{}, error) {
+	if err := opts.validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	requestArgs := map[string]interface{}{
+		"action":             "start",
+		"content-type":       opts.contentType(),
+		"word_confidence":    true,
+		"timestamps":         true,
+		"profanity_filter":   false,
+		"interim_results":    false,
+		"inactivity_timeout": -1,
+		"keywords":           searchWords,
+		"keywords_threshold": 0.5,
+	}
+	if !opts.OmitDeprecatedParameters {
+		requestArgs["continuous"] = true
+	}
+	if opts.GrammarName != "" {
+		requestArgs["grammar_name"] = opts.GrammarName
+	}
+	if opts.SpeakerLabels {
+		requestArgs["speaker_labels"] = true
+	}
+	if opts.SplitTranscriptAtPhraseEnd {
+		requestArgs["split_transcript_at_phrase_end"] = true
+	}
+	if opts.LowLatency {
+		requestArgs["low_latency"] = true
+	}
+	if opts.SmartFormatting {
+		requestArgs["smart_formatting"] = true
+	}
+	if opts.Redaction {
+		requestArgs["redaction"] = true
+	}
+	if opts.EndOfPhraseSilenceTime != nil {
+		requestArgs["end_of_phrase_silence_time"] = *opts.EndOfPhraseSilenceTime
+	}
+	if opts.CharacterInsertionBias != 0 {
+		requestArgs["character_insertion_bias"] = opts.CharacterInsertionBias
+	}
+	if opts.SpeechDetectorSensitivity != nil {
+		requestArgs["speech_detector_sensitivity"] = *opts.SpeechDetectorSensitivity
+	}
+	if opts.BackgroundAudioSuppression != nil {
+		requestArgs["background_audio_suppression"] = *opts.BackgroundAudioSuppression
+	}
+	if opts.OnProgress != nil {
+		requestArgs["processing_metrics"] = true
+		if opts.ProcessingMetricsInterval > 0 {
+			requestArgs["processing_metrics_interval"] = opts.ProcessingMetricsInterval
+		}
+	}
+	return requestArgs, nil
+}
@@ -0,0 +1,208 @@
+package transcription
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartMessageIncludesGrammarName(t *testing.T) {
+	assert := assert.New(t)
+
+	opts := IBMOptions{CustomizationID: "custom-id", GrammarName: "script"}
+	args, err := opts.startMessage([]string{})
+	assert.NoError(err)
+	assert.Equal("script", args["grammar_name"])
+	assert.Equal("custom-id", opts.query().Get("customization_id"))
+}
+
+func TestStartMessageGrammarNameRequiresCustomizationID(t *testing.T) {
+	assert := assert.New(t)
+
+	opts := IBMOptions{GrammarName: "script"}
+	_, err := opts.startMessage([]string{})
+	assert.Error(err)
+}
+
+func TestStartMessageIncludesEndOfPhraseSilenceTime(t *testing.T) {
+	assert := assert.New(t)
+
+	args, err := IBMOptions{EndOfPhraseSilenceTime: Float64(1.5)}.startMessage([]string{})
+	assert.NoError(err)
+	assert.Equal(1.5, args["end_of_phrase_silence_time"])
+
+	// an explicit zero is sent
+	args, err = IBMOptions{EndOfPhraseSilenceTime: Float64(0)}.startMessage([]string{})
+	assert.NoError(err)
+	assert.Equal(0.0, args["end_of_phrase_silence_time"])
+
+	args, err = IBMOptions{}.startMessage([]string{})
+	assert.NoError(err)
+	_, ok := args["end_of_phrase_silence_time"]
+	assert.False(ok)
+
+	_, err = IBMOptions{EndOfPhraseSilenceTime: Float64(120.5)}.startMessage([]string{})
+	assert.Error(err)
+	_, err = IBMOptions{EndOfPhraseSilenceTime: Float64(-1)}.startMessage([]string{})
+	assert.Error(err)
+}
+
+func TestStartMessageIncludesCharacterInsertionBias(t *testing.T) {
+	assert := assert.New(t)
+
+	args, err := IBMOptions{CharacterInsertionBias: -0.3}.startMessage([]string{})
+	assert.NoError(err)
+	assert.Equal(-0.3, args["character_insertion_bias"])
+
+	args, err = IBMOptions{}.startMessage([]string{})
+	assert.NoError(err)
+	_, ok := args["character_insertion_bias"]
+	assert.False(ok)
+
+	_, err = IBMOptions{CharacterInsertionBias: 1.5}.startMessage([]string{})
+	assert.Error(err)
+	_, err = IBMOptions{CharacterInsertionBias: -1.01}.startMessage([]string{})
+	assert.Error(err)
+}
+
+func TestStartMessageIncludesNoiseOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	args, err := IBMOptions{SpeechDetectorSensitivity: Float64(0.3), BackgroundAudioSuppression: Float64(0.6)}.startMessage([]string{})
+	assert.NoError(err)
+	assert.Equal(0.3, args["speech_detector_sensitivity"])
+	assert.Equal(0.6, args["background_audio_suppression"])
+
+	// an explicit zero is sent
+	args, err = IBMOptions{SpeechDetectorSensitivity: Float64(0), BackgroundAudioSuppression: Float64(0)}.startMessage([]string{})
+	assert.NoError(err)
+	assert.Equal(0.0, args["speech_detector_sensitivity"])
+	assert.Equal(0.0, args["background_audio_suppression"])
+
+	args, err = IBMOptions{}.startMessage([]string{})
+	assert.NoError(err)
+	_, ok := args["speech_detector_sensitivity"]
+	assert.False(ok)
+	_, ok = args["background_audio_suppression"]
+	assert.False(ok)
+
+	_, err = IBMOptions{SpeechDetectorSensitivity: Float64(1.1)}.startMessage([]string{})
+	assert.Error(err)
+	_, err = IBMOptions{SpeechDetectorSensitivity: Float64(-0.1)}.startMessage([]string{})
+	assert.Error(err)
+	_, err = IBMOptions{BackgroundAudioSuppression: Float64(2)}.startMessage([]string{})
+	assert.Error(err)
+	_, err = IBMOptions{BackgroundAudioSuppression: Float64(-1)}.startMessage([]string{})
+	assert.Error(err)
+}
+
+func TestStartMessageIncludesLowLatency(t *testing.T) {
+	assert := assert.New(t)
+
+	args, err := IBMOptions{Model: "en-US_Telephony", LowLatency: true}.startMessage([]string{})
+	assert.NoError(err)
+	assert.Equal(true, args["low_latency"])
+
+	_, err = IBMOptions{LowLatency: true}.startMessage([]string{})
+	assert.Error(err)
+}
+
+func TestStartMessageIncludesRedaction(t *testing.T) {
+	assert := assert.New(t)
+
+	args, err := IBMOptions{SmartFormatting: true, Redaction: true}.startMessage([]string{})
+	assert.NoError(err)
+	assert.Equal(true, args["smart_formatting"])
+	assert.Equal(true, args["redaction"])
+
+	_, err = IBMOptions{Redaction: true}.startMessage([]string{})
+	assert.Error(err)
+}
+
+func TestStartMessageIncludesSampleRateOfRawAudio(t *testing.T) {
+	assert := assert.New(t)
+
+	args, err := IBMOptions{ContentType: "audio/l16", SampleRate: 16000}.startMessage([]string{})
+	assert.NoError(err)
+	assert.Equal("audio/l16;rate=16000", args["content-type"])
+
+	// a rate in the content type is kept
+	args, err = IBMOptions{ContentType: "audio/mulaw;rate=8000"}.startMessage([]string{})
+	assert.NoError(err)
+	assert.Equal("audio/mulaw;rate=8000", args["content-type"])
+
+	// other formats have a header with the rate
+	args, err = IBMOptions{ContentType: "audio/wav", SampleRate: 16000}.startMessage([]string{})
+	assert.NoError(err)
+	assert.Equal("audio/wav", args["content-type"])
+
+	_, err = IBMOptions{ContentType: "audio/l16"}.startMessage([]string{})
+	assert.Error(err)
+}
+
+func TestStartMessageOmitsDeprecatedParameters(t *testing.T) {
+	assert := assert.New(t)
+
+	args, err := IBMOptions{}.startMessage([]string{})
+	assert.NoError(err)
+	assert.Equal(true, args["continuous"])
+
+	args, err = IBMOptions{OmitDeprecatedParameters: true}.startMessage([]string{})
+	assert.NoError(err)
+	_, ok := args["continuous"]
+	assert.False(ok)
+	assert.Equal(true, args["timestamps"])
+}
+
+func TestAutoSelectModelChoosesNarrowbandFor8kHz(t *testing.T) {
+	assert := assert.New(t)
+
+	opts := IBMOptions{AutoSelectModel: true}.withModelForFile("test.wav")
+	assert.Equal("en-US_NarrowbandModel", opts.model())
+
+	opts = IBMOptions{AutoSelectModel: true}.withModelForFile("test.flac")
+	assert.Equal("en-US_BroadbandModel", opts.model())
+}
+
+func TestAutoSelectModelKeepsExplicitModel(t *testing.T) {
+	assert := assert.New(t)
+
+	opts := IBMOptions{AutoSelectModel: true, Model: "en-GB_BroadbandModel"}.withModelForFile("test.wav")
+	assert.Equal("en-GB_BroadbandModel", opts.model())
+}
+
+func TestStartMessageSplitTranscriptAtPhraseEnd(t *testing.T) {
+	assert := assert.New(t)
+
+	args, err := IBMOptions{}.startMessage([]string{})
+	assert.NoError(err)
+	_, ok := args["split_transcript_at_phrase_end"]
+	assert.False(ok)
+
+	args, err = IBMOptions{SplitTranscriptAtPhraseEnd: true}.startMessage([]string{})
+	assert.NoError(err)
+	assert.Equal(true, args["split_transcript_at_phrase_end"])
+}
+
+func TestDialerUsesHandshakeTimeoutAndProxy(t *testing.T) {
+	assert := assert.New(t)
+
+	proxyURL, _ := url.Parse("http://proxy.example.com:8080")
+	opts := IBMOptions{
+		HandshakeTimeout: 3 * time.Second,
+		Proxy:            http.ProxyURL(proxyURL),
+	}
+	dialer := opts.dialer()
+	assert.Equal(3*time.Second, dialer.HandshakeTimeout)
+	proxy, err := dialer.Proxy(&http.Request{})
+	assert.NoError(err)
+	assert.Equal(proxyURL, proxy)
+
+	// the shared default dialer is left alone
+	assert.Equal(time.Duration(0), websocket.DefaultDialer.HandshakeTimeout)
+	assert.NotNil(IBMOptions{}.dialer().Proxy)
+}
@@ -0,0 +1,486 @@
+package transcription
+
+import (
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// FinalResults returns the segments of res which IBM marked final, leaving out
+// interim results. Exporters such as GetTranscription and ToTranscript only
+// use final results.
+func FinalResults(res *IBMResult) []ibmResultField {
+	final := []ibmResultField{}
+	for _, subResult := range res.Results {
+		if subResult.Final {
+			final = append(final, subResult)
+		}
+	}
+	return final
+}
+
+// BestAlternatives returns the alternative with the highest overall
+// confidence for each segment of an IBMResult.
+func BestAlternatives(res *IBMResult) []ibmAlternativesField {
+	best := []ibmAlternativesField{}
+	for _, subResult := range res.Results {
+		if len(subResult.Alternatives) == 0 {
+			continue
+		}
+		bestAlternative := subResult.Alternatives[0]
+		for _, alternative := range subResult.Alternatives[1:] {
+			if alternative.OverallConfidence > bestAlternative.OverallConfidence {
+				bestAlternative = alternative
+			}
+		}
+		best = append(best, bestAlternative)
+	}
+	return best
+}
+
+// TailSeconds returns the words which end within the last n seconds of the
+// recording, separated by spaces. The recording is taken to end with its last
+// word.
+func (r *IBMResult) TailSeconds(n float64) string {
+	words := r.words()
+	if len(words) == 0 {
+		return ""
+	}
+	since := words[len(words)-1].EndTime - n
+	tail := []string{}
+	for _, word := range words {
+		if word.EndTime >= since {
+			tail = append(tail, word.Word)
+		}
+	}
+	return NormalizeText(strings.Join(tail, " "))
+}
+
+// lowConfidencePlaceholder stands for the words dropped by HighConfidenceText.
+const lowConfidencePlaceholder = "[...]"
+
+// HighConfidenceText returns the words of the final segments of r whose
+// confidence is at least threshold, separated by spaces, such as for
+// publishing without review. Each run of dropped words is replaced by a
+// single [...].
+func (r *IBMResult) HighConfidenceText(threshold float64) string {
+	text := []string{}
+	dropped := false
+	for _, word := range r.ToTranscript().Words() {
+		if word.Confidence >= threshold {
+			text = append(text, word.Text)
+			dropped = false
+		} else if !dropped {
+			text = append(text, lowConfidencePlaceholder)
+			dropped = true
+		}
+	}
+	return NormalizeText(strings.Join(text, " "))
+}
+
+// TranscriptBlock is a piece of a transcript spanning a period of time.
+type TranscriptBlock struct {
+	Start float64
+	End   float64
+	Text  string
+}
+
+// words returns the timestamped words of the best hypothesis of each final
+// segment, in order.
+func (r *IBMResult) words() []timestamp {
+	words := []timestamp{}
+	for _, subResult := range FinalResults(r) {
+		if len(subResult.Alternatives) == 0 {
+			continue
+		}
+		for _, ibmTimestamp := range subResult.Alternatives[0].Timestamps {
+			words = append(words, timestamp{
+				Word:      ibmTimestamp[0].(string),
+				StartTime: ibmTimestamp[1].(float64),
+				EndTime:   ibmTimestamp[2].(float64),
+			})
+		}
+	}
+	return words
+}
+
+// BlocksByDuration splits the transcript into consecutive windows of
+// windowSeconds. Each block contains the words which start within its window.
+// Windows without any words are omitted.
+func (r *IBMResult) BlocksByDuration(windowSeconds float64) []TranscriptBlock {
+	blocks := []TranscriptBlock{}
+	if windowSeconds <= 0 {
+		return blocks
+	}
+
+	var text []string
+	current := -1
+	flush := func() {
+		if len(text) > 0 {
+			blocks = append(blocks, TranscriptBlock{
+				Start: float64(current) * windowSeconds,
+				End:   float64(current+1) * windowSeconds,
+				Text:  NormalizeText(strings.Join(text, " ")),
+			})
+		}
+		text = nil
+	}
+
+	for _, word := range r.words() {
+		window := int(math.Floor(word.StartTime / windowSeconds))
+		if window != current {
+			flush()
+			current = window
+		}
+		text = append(text, word.Word)
+	}
+	flush()
+	return blocks
+}
+
+// WordWindows splits the transcript into consecutive windows of n words, such
+// as for flashcards. Each block spans from the start of its first word to the
+// end of its last. The last window has fewer words if the number of words is
+// not a multiple of n. Like every export, it only uses final segments.
+func (r *IBMResult) WordWindows(n int) []TranscriptBlock {
+	blocks := []TranscriptBlock{}
+	if n <= 0 {
+		return blocks
+	}
+
+	words := r.words()
+	for start := 0; start < len(words); start += n {
+		end := start + n
+		if end > len(words) {
+			end = len(words)
+		}
+		text := make([]string, end-start)
+		for i, word := range words[start:end] {
+			text[i] = word.Word
+		}
+		blocks = append(blocks, TranscriptBlock{
+			Start: words[start].StartTime,
+			End:   words[end-1].EndTime,
+			Text:  NormalizeText(strings.Join(text, " ")),
+		})
+	}
+	return blocks
+}
+
+// SpeakerWord is a timestamped word and the speaker who said it. Speaker is -1
+// if the speaker is unknown.
+type SpeakerWord struct {
+	Word    string
+	Start   float64
+	End     float64
+	Speaker int
+}
+
+// WordsWithSpeakers joins the words of res with its speaker labels. Each word is
+// attributed to the speaker label which overlaps it the most.
+func WordsWithSpeakers(res *IBMResult) []SpeakerWord {
+	words := []SpeakerWord{}
+	for _, word := range res.words() {
+		speaker := -1
+		bestOverlap := -1.0
+		for _, label := range res.SpeakerLabels {
+			overlap := math.Min(word.EndTime, label.To) - math.Max(word.StartTime, label.From)
+			if overlap < 0 {
+				continue
+			}
+			if overlap > bestOverlap {
+				speaker = label.Speaker
+				bestOverlap = overlap
+			}
+		}
+		words = append(words, SpeakerWord{
+			Word:    word.Word,
+			Start:   word.StartTime,
+			End:     word.EndTime,
+			Speaker: speaker,
+		})
+	}
+	return words
+}
+
+// ShiftTimestamps adds offsetSeconds to every time in res, so that results for
+// trimmed audio line up with the original file. Times are clamped at zero.
+func ShiftTimestamps(res *IBMResult, offsetSeconds float64) {
+	shift := func(t float64) float64 {
+		return math.Max(0, t+offsetSeconds)
+	}
+
+	for i := range res.Results {
+		subResult := &res.Results[i]
+		for j := range subResult.Alternatives {
+			timestamps := subResult.Alternatives[j].Timestamps
+			for k := range timestamps {
+				timestamps[k][1] = shift(timestamps[k][1].(float64))
+				timestamps[k][2] = shift(timestamps[k][2].(float64))
+			}
+		}
+		for _, keywords := range subResult.KeywordMap {
+			for k := range keywords {
+				keywords[k].StartTime = shift(keywords[k].StartTime)
+				keywords[k].EndTime = shift(keywords[k].EndTime)
+			}
+		}
+	}
+	for i := range res.SpeakerLabels {
+		res.SpeakerLabels[i].From = shift(res.SpeakerLabels[i].From)
+		res.SpeakerLabels[i].To = shift(res.SpeakerLabels[i].To)
+	}
+}
+
+// RepairTimestamps makes the times of the words of the best hypothesis of each
+// segment of res monotonic, since IBM occasionally sends words which start
+// before the previous word ends, even across segments, which breaks captions.
+// Such a word is moved to start when the previous word ends, and a word which
+// ends before it starts is made to end when it starts. The number of repaired
+// words is logged.
+func RepairTimestamps(res *IBMResult) {
+	repaired := 0
+	previousEnd := 0.0
+	for i := range res.Results {
+		if len(res.Results[i].Alternatives) == 0 {
+			continue
+		}
+		timestamps := res.Results[i].Alternatives[0].Timestamps
+		for k := range timestamps {
+			start, end := timestamps[k][1].(float64), timestamps[k][2].(float64)
+			if start < previousEnd || end < start {
+				start = math.Max(start, previousEnd)
+				end = math.Max(end, start)
+				timestamps[k][1], timestamps[k][2] = start, end
+				repaired++
+			}
+			previousEnd = end
+		}
+	}
+	if repaired > 0 {
+		log.Warnf("Repaired the timestamps of %d words which were out of order", repaired)
+	}
+}
+
+// Slice returns a new result with the words of r which are within start and
+// end, in seconds, such as for the transcript of a clip. Times are relative
+// to start. Segments without such words are dropped, as are the alternative
+// hypotheses of segments which are cut. Speaker labels and keywords are kept
+// if they are within the window.
+func (r *IBMResult) Slice(start, end float64) *IBMResult {
+	sliced := r.filterWords(func(from, to float64) bool {
+		return from >= start && to <= end
+	})
+	ShiftTimestamps(sliced, -start)
+	return sliced
+}
+
+// SplitAtMarkers splits r at markers, in seconds, such as the chapters of a
+// podcast, and returns one result for the time before the first marker, one
+// between each pair of markers and one after the last marker. A word belongs
+// to the part in which it starts. Times are kept relative to the whole
+// recording. Parts without words are empty results.
+func (r *IBMResult) SplitAtMarkers(markers []float64) []*IBMResult {
+	bounds := append([]float64(nil), markers...)
+	sort.Float64s(bounds)
+	parts := make([]*IBMResult, len(bounds)+1)
+	for i := range parts {
+		start, end := math.Inf(-1), math.Inf(1)
+		if i > 0 {
+			start = bounds[i-1]
+		}
+		if i < len(bounds) {
+			end = bounds[i]
+		}
+		parts[i] = r.filterWords(func(from, to float64) bool {
+			return from >= start && from < end
+		})
+	}
+	return parts
+}
+
+// filterWords returns a new result with the words of r for which keep returns
+// true. Segments without such words are dropped, as are the alternative
+// hypotheses of segments which are cut. Speaker labels and keywords are kept
+// if keep returns true for them.
+func (r *IBMResult) filterWords(keep func(from, to float64) bool) *IBMResult {
+	filtered := &IBMResult{Model: r.Model}
+	for _, result := range r.Results {
+		if len(result.Alternatives) == 0 || len(result.Alternatives[0].Timestamps) == 0 {
+			continue
+		}
+		result, ok := withoutWords(result, func(word timestamp) bool {
+			return !keep(word.StartTime, word.EndTime)
+		})
+		if !ok {
+			continue
+		}
+		filtered.Results = append(filtered.Results, copyResultField(result, keep))
+	}
+	for _, label := range r.SpeakerLabels {
+		if keep(label.From, label.To) {
+			filtered.SpeakerLabels = append(filtered.SpeakerLabels, label)
+		}
+	}
+	return filtered
+}
+
+// copyResultField returns a copy of result which shares no slices or maps with
+// it, keeping only the keywords for which keep returns true.
+func copyResultField(result ibmResultField, keep func(from, to float64) bool) ibmResultField {
+	copied := ibmResultField{Final: result.Final}
+	for _, alternative := range result.Alternatives {
+		alternative.Timestamps = append([]ibmWordTimestamp(nil), alternative.Timestamps...)
+		alternative.WordConfidence = append([]ibmWordConfidence(nil), alternative.WordConfidence...)
+		copied.Alternatives = append(copied.Alternatives, alternative)
+	}
+	for word, keywords := range result.KeywordMap {
+		for _, keyword := range keywords {
+			if !keep(keyword.StartTime, keyword.EndTime) {
+				continue
+			}
+			if copied.KeywordMap == nil {
+				copied.KeywordMap = map[string][]ibmKeywordResult{}
+			}
+			copied.KeywordMap[word] = append(copied.KeywordMap[word], keyword)
+		}
+	}
+	return copied
+}
+
+// Stats summarizes the recognized words of an IBMResult.
+type Stats struct {
+	// Words is the number of words in the transcript.
+	Words int
+	// FinalSegments is the number of segments which IBM marked final.
+	FinalSegments int
+	// AverageConfidence, MinConfidence and MaxConfidence describe the
+	// confidence of the words in the transcript.
+	AverageConfidence float64
+	MinConfidence     float64
+	MaxConfidence     float64
+	// Duration is the time in seconds from the start of the first word to the
+	// end of the last.
+	Duration float64
+}
+
+// TranscriptStats returns statistics about the best hypothesis of each final
+// segment of res.
+func TranscriptStats(res *IBMResult) Stats {
+	stats := Stats{}
+	words := res.words()
+	stats.Words = len(words)
+	if len(words) > 0 {
+		stats.Duration = words[len(words)-1].EndTime - words[0].StartTime
+	}
+
+	confidences := 0
+	total := 0.0
+	for _, subResult := range FinalResults(res) {
+		stats.FinalSegments++
+		if len(subResult.Alternatives) == 0 {
+			continue
+		}
+		for _, wordConfidence := range subResult.Alternatives[0].WordConfidence {
+			confidence := wordConfidence[1].(float64)
+			if confidences == 0 || confidence < stats.MinConfidence {
+				stats.MinConfidence = confidence
+			}
+			if confidences == 0 || confidence > stats.MaxConfidence {
+				stats.MaxConfidence = confidence
+			}
+			total += confidence
+			confidences++
+		}
+	}
+	if confidences > 0 {
+		stats.AverageConfidence = total / float64(confidences)
+	}
+	return stats
+}
+
+// SilenceRatio returns the fraction of the span of res, from the start of its
+// first word to the end of its last word, which is not covered by any word.
+// It is 0 if res has no words. A high ratio flags mostly empty recordings.
+func SilenceRatio(res *IBMResult) float64 {
+	words := res.words()
+	if len(words) == 0 {
+		return 0
+	}
+	span := words[len(words)-1].EndTime - words[0].StartTime
+	if span <= 0 {
+		return 0
+	}
+	silence := 0.0
+	for i := 1; i < len(words); i++ {
+		if gap := words[i].StartTime - words[i-1].EndTime; gap > 0 {
+			silence += gap
+		}
+	}
+	return silence / span
+}
+
+// TextReader returns a reader of the transcript of r, which is the same text
+// as the Transcript of GetTranscription. The text of each final segment is
+// read in turn, so the whole transcript is never held in one string.
+func (r *IBMResult) TextReader() io.Reader {
+	return &textReader{results: FinalResults(r)}
+}
+
+// textReader reads the transcript of the best hypothesis of each result.
+type textReader struct {
+	results []ibmResultField
+	// text is the unread text of the current result.
+	text string
+	// last is the normalized text of the last result which had any.
+	last string
+}
+
+func (t *textReader) Read(p []byte) (int, error) {
+	for t.text == "" {
+		if len(t.results) == 0 {
+			return 0, io.EOF
+		}
+		if len(t.results[0].Alternatives) > 0 {
+			text := NormalizeText(t.results[0].Alternatives[0].Transcript)
+			if text != "" {
+				t.text = textSeparator(t.last, text) + text
+				t.last = text
+			}
+		}
+		t.results = t.results[1:]
+	}
+	n := copy(p, t.text)
+	t.text = t.text[n:]
+	return n, nil
+}
+
+// SegmentConfidences returns the overall confidence of the best hypothesis of
+// each final segment of res.
+func SegmentConfidences(res *IBMResult) []float64 {
+	confidences := []float64{}
+	for _, subResult := range FinalResults(res) {
+		if len(subResult.Alternatives) == 0 {
+			continue
+		}
+		confidences = append(confidences, subResult.Alternatives[0].OverallConfidence)
+	}
+	return confidences
+}
+
+// MinConfidence returns the lowest confidence of any segment of res, or 0 if
+// res has no segments.
+func MinConfidence(res *IBMResult) float64 {
+	confidences := SegmentConfidences(res)
+	if len(confidences) == 0 {
+		return 0
+	}
+	min := confidences[0]
+	for _, confidence := range confidences[1:] {
+		min = math.Min(min, confidence)
+	}
+	return min
+}
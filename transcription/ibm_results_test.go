@@ -0,0 +1,378 @@
+package transcription
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBestAlternativesChoosesHighestConfidence(t *testing.T) {
+	assert := assert.New(t)
+
+	res := &IBMResult{
+		Results: []ibmResultField{
+			ibmResultField{
+				Alternatives: []ibmAlternativesField{
+					ibmAlternativesField{Transcript: "first ", OverallConfidence: 0.9},
+					ibmAlternativesField{Transcript: "worst ", OverallConfidence: 0.2},
+				},
+			},
+			ibmResultField{
+				Alternatives: []ibmAlternativesField{
+					ibmAlternativesField{Transcript: "low ", OverallConfidence: 0.4},
+					ibmAlternativesField{Transcript: "high ", OverallConfidence: 0.8},
+				},
+			},
+		},
+	}
+
+	best := BestAlternatives(res)
+	assert.Len(best, 2)
+	assert.Equal("first ", best[0].Transcript)
+	assert.Equal("high ", best[1].Transcript)
+}
+
+// newTimestampedResult returns an IBMResult with one segment per element of
+// segments. Each word is given as {word, start, end}.
+func newTimestampedResult(segments ...[]ibmWordTimestamp) *IBMResult {
+	res := new(IBMResult)
+	for _, words := range segments {
+		transcript := ""
+		for _, word := range words {
+			transcript += word[0].(string) + " "
+		}
+		res.Results = append(res.Results, ibmResultField{
+			Alternatives: []ibmAlternativesField{
+				ibmAlternativesField{
+					Transcript: transcript,
+					Timestamps: words,
+				},
+			},
+			Final: true,
+		})
+	}
+	return res
+}
+
+func TestBlocksByDuration(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{
+			{"hello", 0.5, 1.0},
+			{"world", 59.0, 59.9},
+		},
+		[]ibmWordTimestamp{
+			{"second", 60.0, 60.5},
+			{"minute", 61.0, 62.0},
+			{"later", 185.0, 186.0},
+		},
+	)
+
+	blocks := res.BlocksByDuration(60)
+	assert.Equal([]TranscriptBlock{
+		{Start: 0, End: 60, Text: "hello world"},
+		{Start: 60, End: 120, Text: "second minute"},
+		{Start: 180, End: 240, Text: "later"},
+	}, blocks)
+}
+
+func TestRepairTimestamps(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{{"one", 0.0, 1.0}, {"two", 0.8, 1.5}, {"three", 1.5, 1.4}},
+		[]ibmWordTimestamp{{"four", 1.2, 2.0}, {"five", 2.5, 3.0}},
+	)
+	RepairTimestamps(res)
+	assert.Equal([]ibmWordTimestamp{{"one", 0.0, 1.0}, {"two", 1.0, 1.5}, {"three", 1.5, 1.5}}, res.Results[0].Alternatives[0].Timestamps)
+	// segments are repaired across their boundaries
+	assert.Equal([]ibmWordTimestamp{{"four", 1.5, 2.0}, {"five", 2.5, 3.0}}, res.Results[1].Alternatives[0].Timestamps)
+}
+
+func TestSplitAtMarkers(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{{"intro", 0.0, 1.0}, {"music", 1.0, 2.0}},
+		[]ibmWordTimestamp{{"first", 10.0, 11.0}, {"chapter", 11.0, 12.0}},
+		[]ibmWordTimestamp{{"still", 19.5, 20.5}, {"second", 21.0, 22.0}},
+	)
+	res.SpeakerLabels = []ibmSpeakerLabel{{From: 10.0, To: 11.0, Speaker: 1}}
+
+	parts := res.SplitAtMarkers([]float64{20, 10})
+	if !assert.Len(parts, 3) {
+		return
+	}
+	assert.Equal([]string{"intro music "}, transcripts(parts[0]))
+	// a word belongs to the part it starts in
+	assert.Equal([]string{"first chapter ", "still "}, transcripts(parts[1]))
+	assert.Equal([]string{"second "}, transcripts(parts[2]))
+	// timestamps are not shifted
+	assert.Equal([]ibmWordTimestamp{{"second", 21.0, 22.0}}, parts[2].Results[0].Alternatives[0].Timestamps)
+	assert.Equal([]ibmSpeakerLabel{{From: 10.0, To: 11.0, Speaker: 1}}, parts[1].SpeakerLabels)
+	assert.Empty(parts[0].SpeakerLabels)
+}
+
+func TestHighConfidenceText(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{{"the", 0.0, 0.2}, {"plains", 0.2, 0.6}, {"umm", 0.6, 0.8}, {"were", 0.8, 1.0}},
+		[]ibmWordTimestamp{{"awesome", 1.5, 2.0}, {"blah", 2.0, 2.2}},
+	)
+	res.Results[0].Alternatives[0].WordConfidence = []ibmWordConfidence{{"the", 0.9}, {"plains", 0.3}, {"umm", 0.2}, {"were", 0.8}}
+	res.Results[1].Alternatives[0].WordConfidence = []ibmWordConfidence{{"awesome", 0.7}, {"blah", 0.1}}
+
+	assert.Equal("the [...] were awesome [...]", res.HighConfidenceText(0.7))
+	assert.Equal("the plains umm were awesome blah", res.HighConfidenceText(0))
+}
+
+func TestWordWindows(t *testing.T) {
+	assert := assert.New(t)
+
+	words := []ibmWordTimestamp{}
+	for i := 0; i < 10; i++ {
+		words = append(words, ibmWordTimestamp{strconv.Itoa(i), float64(i), float64(i) + 0.5})
+	}
+	res := newTimestampedResult(words[:6], []ibmWordTimestamp{{"sex", 6.0, 6.4}}, words[6:])
+	res.Results[1].Final = false
+
+	assert.Equal([]TranscriptBlock{
+		{Start: 0, End: 3.5, Text: "0 1 2 3"},
+		{Start: 4, End: 7.5, Text: "4 5 6 7"},
+		{Start: 8, End: 9.5, Text: "8 9"},
+	}, res.WordWindows(4))
+	assert.Empty(res.WordWindows(0))
+}
+
+func TestWordsWithSpeakers(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{
+			{"hi", 0.0, 0.5},
+			{"there", 0.5, 1.0},
+			{"hello", 1.5, 2.0},
+			{"unlabeled", 5.0, 6.0},
+		},
+	)
+	res.SpeakerLabels = []ibmSpeakerLabel{
+		{From: 0.0, To: 0.5, Speaker: 0},
+		{From: 0.5, To: 1.0, Speaker: 0},
+		{From: 1.5, To: 2.0, Speaker: 1},
+	}
+
+	assert.Equal([]SpeakerWord{
+		{Word: "hi", Start: 0.0, End: 0.5, Speaker: 0},
+		{Word: "there", Start: 0.5, End: 1.0, Speaker: 0},
+		{Word: "hello", Start: 1.5, End: 2.0, Speaker: 1},
+		{Word: "unlabeled", Start: 5.0, End: 6.0, Speaker: -1},
+	}, WordsWithSpeakers(res))
+}
+
+func TestShiftTimestamps(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{
+			{"hello", 0.5, 1.0},
+			{"world", 2.0, 3.0},
+		},
+	)
+	res.SpeakerLabels = []ibmSpeakerLabel{{From: 0.5, To: 1.0}}
+
+	ShiftTimestamps(res, 10)
+	assert.Equal([]ibmWordTimestamp{
+		{"hello", 10.5, 11.0},
+		{"world", 12.0, 13.0},
+	}, res.Results[0].Alternatives[0].Timestamps)
+	assert.Equal(10.5, res.SpeakerLabels[0].From)
+
+	ShiftTimestamps(res, -11)
+	assert.Equal([]ibmWordTimestamp{
+		{"hello", 0.0, 0.0},
+		{"world", 1.0, 2.0},
+	}, res.Results[0].Alternatives[0].Timestamps)
+	assert.Equal(0.0, res.SpeakerLabels[0].From)
+}
+
+func TestTranscriptStats(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{
+			{"hello", 0.5, 1.0},
+			{"there", 1.0, 1.4},
+		},
+		[]ibmWordTimestamp{
+			{"hi", 2.0, 2.5},
+		},
+	)
+	res.Results[0].Alternatives[0].WordConfidence = []ibmWordConfidence{{"hello", 0.9}, {"there", 0.6}}
+	res.Results[1].Alternatives[0].WordConfidence = []ibmWordConfidence{{"hi", 0.75}}
+	res.Results[1].Final = false
+
+	stats := TranscriptStats(res)
+	assert.Equal(2, stats.Words)
+	assert.Equal(1, stats.FinalSegments)
+	assert.InDelta(0.75, stats.AverageConfidence, 1e-9)
+	assert.Equal(0.6, stats.MinConfidence)
+	assert.Equal(0.9, stats.MaxConfidence)
+	assert.InDelta(0.9, stats.Duration, 1e-9)
+
+	assert.Equal(Stats{}, TranscriptStats(new(IBMResult)))
+}
+
+func TestSilenceRatio(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{
+			{"hello", 1.0, 2.0},
+			{"there", 4.0, 5.0},
+		},
+		[]ibmWordTimestamp{
+			{"hi", 8.0, 9.0},
+		},
+	)
+	// gaps of 2 and 3 seconds over a span of 8 seconds
+	assert.InDelta(5.0/8.0, SilenceRatio(res), 1e-9)
+
+	assert.Equal(0.0, SilenceRatio(new(IBMResult)))
+}
+
+func TestSlice(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{
+			{"one", 0.0, 1.0},
+			{"two", 1.0, 2.0},
+		},
+		[]ibmWordTimestamp{
+			{"three", 3.0, 4.0},
+			{"four", 4.0, 5.0},
+			{"five", 5.0, 6.0},
+		},
+		[]ibmWordTimestamp{
+			{"six", 7.0, 8.0},
+		},
+	)
+	res.Results[1].Alternatives[0].WordConfidence = []ibmWordConfidence{{"three", 0.9}, {"four", 0.8}, {"five", 0.7}}
+	res.Results[1].KeywordMap = map[string][]ibmKeywordResult{
+		"four": {{Word: "four", StartTime: 4.0, EndTime: 5.0}},
+		"five": {{Word: "five", StartTime: 5.0, EndTime: 6.0}},
+	}
+	res.SpeakerLabels = []ibmSpeakerLabel{{From: 1.0, To: 2.0, Speaker: 0}, {From: 4.0, To: 5.0, Speaker: 1}}
+
+	sliced := res.Slice(1.0, 5.0)
+	assert.Equal([]string{"two ", "three four "}, transcripts(sliced))
+	assert.Equal([]ibmWordTimestamp{{"two", 0.0, 1.0}}, sliced.Results[0].Alternatives[0].Timestamps)
+	assert.Equal([]ibmWordTimestamp{{"three", 2.0, 3.0}, {"four", 3.0, 4.0}}, sliced.Results[1].Alternatives[0].Timestamps)
+	assert.Equal([]ibmWordConfidence{{"three", 0.9}, {"four", 0.8}}, sliced.Results[1].Alternatives[0].WordConfidence)
+	assert.Equal(map[string][]ibmKeywordResult{
+		"four": {{Word: "four", StartTime: 3.0, EndTime: 4.0}},
+	}, sliced.Results[1].KeywordMap)
+	assert.Equal([]ibmSpeakerLabel{{From: 0.0, To: 1.0, Speaker: 0}, {From: 3.0, To: 4.0, Speaker: 1}}, sliced.SpeakerLabels)
+
+	// the original is unchanged
+	assert.Equal(ibmWordTimestamp{"three", 3.0, 4.0}, res.Results[1].Alternatives[0].Timestamps[0])
+	assert.Equal(4.0, res.Results[1].KeywordMap["four"][0].StartTime)
+	assert.Equal(4.0, res.SpeakerLabels[1].From)
+}
+
+func TestTextReader(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{{"hello", 0.5, 1.0}, {"there", 1.0, 1.4}},
+		[]ibmWordTimestamp{},
+		[]ibmWordTimestamp{{"general", 2.0, 2.5}, {"kenobi", 2.5, 3.0}},
+	)
+
+	var text bytes.Buffer
+	reader := res.TextReader()
+	chunk := make([]byte, 3)
+	for {
+		n, err := reader.Read(chunk)
+		text.Write(chunk[:n])
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(err)
+	}
+	assert.Equal(GetTranscription([]*IBMResult{res}).Transcript, text.String())
+}
+
+func TestSegmentConfidences(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{{"hello", 0.5, 1.0}},
+		[]ibmWordTimestamp{{"there", 1.0, 1.4}},
+		[]ibmWordTimestamp{{"hi", 2.0, 2.5}},
+	)
+	res.Results[0].Alternatives[0].OverallConfidence = 0.9
+	res.Results[1].Alternatives[0].OverallConfidence = 0.4
+	res.Results[2].Alternatives[0].OverallConfidence = 0.7
+
+	assert.Equal([]float64{0.9, 0.4, 0.7}, SegmentConfidences(res))
+	assert.Equal(0.4, MinConfidence(res))
+	assert.Equal(0.0, MinConfidence(new(IBMResult)))
+}
+
+func TestTailSeconds(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{{"hello", 0.5, 1.0}, {"there", 1.0, 1.4}},
+		[]ibmWordTimestamp{{"general", 8.0, 8.5}, {"kenobi", 8.5, 9.2}},
+	)
+
+	assert.Equal("general kenobi", res.TailSeconds(1.2))
+	assert.Equal("kenobi", res.TailSeconds(0.5))
+	assert.Equal("there general kenobi", res.TailSeconds(7.8))
+	assert.Equal("", new(IBMResult).TailSeconds(5))
+}
+
+func TestExportsUseFinalResults(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{{"hello", 0.5, 1.0}},
+		[]ibmWordTimestamp{{"ther", 1.0, 1.4}},
+		[]ibmWordTimestamp{{"there", 1.0, 1.5}},
+	)
+	res.Results[1].Final = false
+	res.Results[0].Alternatives[0].OverallConfidence = 0.9
+	res.Results[1].Alternatives[0].OverallConfidence = 0.1
+	res.Results[2].Alternatives[0].OverallConfidence = 0.8
+
+	final := FinalResults(res)
+	assert.Len(final, 2)
+	assert.Equal("hello ", final[0].Alternatives[0].Transcript)
+	assert.Equal("there ", final[1].Alternatives[0].Transcript)
+
+	assert.Equal("hello there", GetTranscription([]*IBMResult{res}).Transcript)
+	assert.Equal("hello there", res.ToTranscript().Text())
+	assert.NotContains(res.ToSRT(), "ther ")
+	text, err := ioutil.ReadAll(res.TextReader())
+	assert.NoError(err)
+	assert.Equal("hello there", string(text))
+
+	assert.Equal("hello there", res.TailSeconds(10))
+	assert.Equal([]TranscriptBlock{{Start: 0, End: 10, Text: "hello there"}}, res.BlocksByDuration(10))
+	assert.Len(WordsWithSpeakers(res), 2)
+	assert.Equal(2, TranscriptStats(res).Words)
+	assert.Equal([]float64{0.9, 0.8}, SegmentConfidences(res))
+	assert.Equal(0.8, MinConfidence(res))
+
+	// the interim word is not part of the overlap with the next chunk
+	next := newTimestampedResult([]ibmWordTimestamp{{"ther", 1.1, 1.3}, {"again", 2.0, 3.0}})
+	assert.Equal("hello there ther again", DedupeOverlap([]*IBMResult{res, next}, 1).ToTranscript().Text())
+}
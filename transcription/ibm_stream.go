@@ -0,0 +1,251 @@
+package transcription
+
+import (
+	"io"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/juju/errors"
+)
+
+// ibmMessage is a message sent by IBM during a recognition request.
+type ibmMessage struct {
+	IBMResult
+	State             string                `json:"state"`
+	Error             string                `json:"error"`
+	ProcessingMetrics *ibmProcessingMetrics `json:"processing_metrics"`
+}
+
+type ibmProcessingMetrics struct {
+	ProcessedAudio struct {
+		Received      float64 `json:"received"`
+		SeenByEngine  float64 `json:"seen_by_engine"`
+		Transcription float64 `json:"transcription"`
+	} `json:"processed_audio"`
+}
+
+// percent returns the percentage of the received audio which is transcribed.
+func (m *ibmProcessingMetrics) percent() float64 {
+	if m.ProcessedAudio.Received <= 0 {
+		return 0
+	}
+	return 100 * m.ProcessedAudio.Transcription / m.ProcessedAudio.Received
+}
+
+// jsonReader is the part of a websocket.Conn used to read IBM messages.
+type jsonReader interface {
+	ReadJSON(v interface{}) error
+}
+
+// readResults reads messages from ws into results until IBM has finished
+// recognizing the uploaded audio. IBM says it is listening once when the
+// request starts and again once all results are sent.
+func readResults(ws jsonReader, results *resultAccumulator, opts IBMOptions) error {
+	listening := 0
+	for {
+		msg := new(ibmMessage)
+		if err := ws.ReadJSON(msg); err != nil {
+			return errors.Trace(err)
+		}
+		if msg.Error != "" {
+			return errors.New(msg.Error)
+		}
+		if msg.ProcessingMetrics != nil && opts.OnProgress != nil {
+			opts.OnProgress(msg.ProcessingMetrics.percent())
+		}
+		for _, warning := range msg.Warnings {
+			if opts.OnWarning != nil {
+				opts.OnWarning(warning)
+			} else {
+				log.Warnf("IBM warning: %s", warning)
+			}
+		}
+		results.add(&msg.IBMResult)
+		if err := results.flush(); err != nil {
+			return errors.Trace(err)
+		}
+		if opts.MaxResults > 0 && len(results.results) > opts.MaxResults {
+			return errors.Errorf("IBM returned more than the maximum of %d results", opts.MaxResults)
+		}
+		if msg.State == "listening" {
+			listening++
+			if listening == 2 {
+				return nil
+			}
+		}
+	}
+}
+
+// readFirstPhrase reads messages from ws until IBM sends a final result and
+// returns its transcript. It fails if IBM finishes recognizing the audio
+// without one.
+func readFirstPhrase(ws jsonReader) (string, error) {
+	listening := 0
+	for {
+		msg := new(ibmMessage)
+		if err := ws.ReadJSON(msg); err != nil {
+			return "", errors.Trace(err)
+		}
+		if msg.Error != "" {
+			return "", errors.New(msg.Error)
+		}
+		for _, result := range msg.Results {
+			if result.Final && len(result.Alternatives) > 0 {
+				return strings.TrimSpace(result.Alternatives[0].Transcript), nil
+			}
+		}
+		if msg.State == "listening" {
+			listening++
+			if listening == 2 {
+				return "", errors.New("IBM did not recognize a phrase")
+			}
+		}
+	}
+}
+
+// resultAccumulator collects the results of a recognition request keyed by
+// result_index. Results which IBM sends again, such as a final result
+// replacing an interim one, overwrite the earlier result at the same index.
+type resultAccumulator struct {
+	results       map[int]ibmResultField
+	speakerLabels []ibmSpeakerLabel
+	warnings      []string
+	// offset is added to the result indices of the current connection.
+	offset int
+	// resumeAt is the end, in seconds, of the last final result received before
+	// the connection dropped. The current connection recognizes the audio from
+	// the start again, so its results which end by then are skipped.
+	resumeAt float64
+	// skipped is the number of leading results of the current connection which
+	// were skipped.
+	skipped int
+	// skipping is whether the current connection has yet to send a result past
+	// resumeAt.
+	skipping bool
+	// raw receives every message of IBM as a line of JSON, if it is set.
+	raw io.Writer
+	// output receives the text of each final result, if it is set.
+	output io.Writer
+	// onFinal is called with each final result and its index, if it is set.
+	onFinal func(index int, result ibmResultField)
+	// written is the number of final results written to output and passed
+	// to onFinal.
+	written int
+}
+
+func newResultAccumulator() *resultAccumulator {
+	return &resultAccumulator{results: make(map[int]ibmResultField)}
+}
+
+// add adds the results of msg to the accumulator.
+func (a *resultAccumulator) add(msg *IBMResult) {
+	for i, result := range msg.Results {
+		index := msg.ResultIndex + i
+		if index < a.skipped {
+			continue
+		}
+		if a.skipping && index == a.skipped && resultEnd(result) <= a.resumeAt {
+			if result.Final {
+				a.skipped++
+			}
+			continue
+		}
+		a.skipping = false
+		a.results[a.offset+index-a.skipped] = result
+	}
+	a.warnings = append(a.warnings, msg.Warnings...)
+	for _, label := range msg.SpeakerLabels {
+		if a.resumeAt == 0 || label.To > a.resumeAt {
+			a.speakerLabels = append(a.speakerLabels, label)
+		}
+	}
+}
+
+// resultEnd returns the end, in seconds, of the last word of result.
+func resultEnd(result ibmResultField) float64 {
+	if len(result.Alternatives) == 0 {
+		return 0
+	}
+	timestamps := result.Alternatives[0].Timestamps
+	if len(timestamps) == 0 {
+		return 0
+	}
+	end, _ := timestamps[len(timestamps)-1][2].(float64)
+	return end
+}
+
+// resume prepares the accumulator for a new connection after the previous one
+// dropped. Results after the last final result are discarded, since the new
+// connection recognizes that audio again, and the result indices of the new
+// connection are appended after the last final result. Results of the new
+// connection which end before the last final result are skipped.
+func (a *resultAccumulator) resume() {
+	final := 0
+	for result, ok := a.results[final]; ok && result.Final; result, ok = a.results[final] {
+		final++
+	}
+	for index := range a.results {
+		if index >= final {
+			delete(a.results, index)
+		}
+	}
+	a.offset = final
+	a.skipped = 0
+	if final > 0 {
+		a.resumeAt = resultEnd(a.results[final-1])
+	}
+	a.skipping = a.resumeAt > 0
+	labels := a.speakerLabels[:0]
+	for _, label := range a.speakerLabels {
+		if label.To <= a.resumeAt {
+			labels = append(labels, label)
+		}
+	}
+	a.speakerLabels = labels
+}
+
+// flush writes the text of the final results which follow those already
+// written to output, one line per result, and passes them to onFinal. Results
+// are written in order, so a final result is held back until all results
+// before it are final.
+func (a *resultAccumulator) flush() error {
+	if a.output == nil && a.onFinal == nil {
+		return nil
+	}
+	for result, ok := a.results[a.written]; ok && result.Final; result, ok = a.results[a.written] {
+		if a.output != nil {
+			text := ""
+			if len(result.Alternatives) > 0 {
+				text = strings.TrimSpace(result.Alternatives[0].Transcript)
+			}
+			if _, err := io.WriteString(a.output, text+"\n"); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if a.onFinal != nil {
+			a.onFinal(a.written, result)
+		}
+		a.written++
+	}
+	return nil
+}
+
+// result returns the accumulated results in order.
+func (a *resultAccumulator) result() *IBMResult {
+	indices := make([]int, 0, len(a.results))
+	for index := range a.results {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+
+	res := &IBMResult{
+		Results:       make([]ibmResultField, 0, len(indices)),
+		SpeakerLabels: a.speakerLabels,
+		Warnings:      a.warnings,
+	}
+	for _, index := range indices {
+		res.Results = append(res.Results, a.results[index])
+	}
+	return res
+}
@@ -0,0 +1,544 @@
+package transcription
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/websocket"
+	"github.com/hack4impact/transcribe4all/transcription/ibmtest"
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTranscriptResult(index int, final bool, transcripts ...string) *IBMResult {
+	res := &IBMResult{ResultIndex: index}
+	for _, transcript := range transcripts {
+		res.Results = append(res.Results, ibmResultField{
+			Alternatives: []ibmAlternativesField{
+				ibmAlternativesField{Transcript: transcript},
+			},
+			Final: final,
+		})
+	}
+	return res
+}
+
+func transcripts(res *IBMResult) []string {
+	transcripts := []string{}
+	for _, result := range res.Results {
+		transcripts = append(transcripts, result.Alternatives[0].Transcript)
+	}
+	return transcripts
+}
+
+func TestResultAccumulatorReplacesInterimResults(t *testing.T) {
+	assert := assert.New(t)
+
+	results := newResultAccumulator()
+	results.add(newTranscriptResult(0, false, "hel"))
+	results.add(newTranscriptResult(0, true, "hello "))
+	results.add(newTranscriptResult(1, true, "world "))
+
+	assert.Equal([]string{"hello ", "world "}, transcripts(results.result()))
+}
+
+func TestResultAccumulatorResumesAfterDrop(t *testing.T) {
+	assert := assert.New(t)
+
+	results := newResultAccumulator()
+	results.add(newTranscriptResult(0, true, "one "))
+	results.add(newTranscriptResult(1, true, "two "))
+	results.add(newTranscriptResult(2, false, "thr"))
+
+	// the connection drops and a new connection starts counting from zero
+	results.resume()
+	results.add(newTranscriptResult(0, false, "thre"))
+	results.add(newTranscriptResult(0, true, "three "))
+	results.add(newTranscriptResult(1, true, "four "))
+
+	assert.Equal([]string{"one ", "two ", "three ", "four "}, transcripts(results.result()))
+}
+
+// scriptedReader reads a scripted sequence of JSON messages.
+type scriptedReader struct {
+	messages []string
+}
+
+func (r *scriptedReader) ReadJSON(v interface{}) error {
+	if len(r.messages) == 0 {
+		return io.EOF
+	}
+	message := r.messages[0]
+	r.messages = r.messages[1:]
+	return json.Unmarshal([]byte(message), v)
+}
+
+func TestReadResultsReportsProgress(t *testing.T) {
+	assert := assert.New(t)
+
+	ws := &scriptedReader{messages: []string{
+		`{"state": "listening"}`,
+		`{"processing_metrics": {"processed_audio": {"received": 20.0, "seen_by_engine": 10.0, "transcription": 5.0}}}`,
+		`{"result_index": 0, "results": [{"alternatives": [{"transcript": "hello "}], "final": true}]}`,
+		`{"processing_metrics": {"processed_audio": {"received": 20.0, "seen_by_engine": 20.0, "transcription": 15.0}}}`,
+		`{"processing_metrics": {"processed_audio": {"received": 20.0, "seen_by_engine": 20.0, "transcription": 20.0}}}`,
+		`{"state": "listening"}`,
+	}}
+
+	progress := []float64{}
+	opts := IBMOptions{OnProgress: func(percent float64) {
+		progress = append(progress, percent)
+	}}
+	results := newResultAccumulator()
+	assert.NoError(readResults(ws, results, opts))
+	assert.Equal([]float64{25, 75, 100}, progress)
+	assert.Equal([]string{"hello "}, transcripts(results.result()))
+
+	args, err := opts.startMessage([]string{})
+	assert.NoError(err)
+	assert.Equal(true, args["processing_metrics"])
+}
+
+func TestReadResultsReturnsIBMError(t *testing.T) {
+	assert := assert.New(t)
+
+	ws := &scriptedReader{messages: []string{
+		`{"state": "listening"}`,
+		`{"error": "unable to transcode data stream audio/flac -> audio/x-float-array"}`,
+	}}
+	err := readResults(ws, newResultAccumulator(), IBMOptions{})
+	assert.Error(err)
+}
+
+func TestReadResultsEnforcesMaxResults(t *testing.T) {
+	assert := assert.New(t)
+
+	newReader := func() *scriptedReader {
+		return &scriptedReader{messages: []string{
+			`{"state": "listening"}`,
+			`{"result_index": 0, "results": [{"alternatives": [{"transcript": "one "}], "final": true}]}`,
+			`{"result_index": 1, "results": [{"alternatives": [{"transcript": "two "}], "final": true}]}`,
+			`{"result_index": 2, "results": [{"alternatives": [{"transcript": "three "}], "final": true}]}`,
+			`{"state": "listening"}`,
+		}}
+	}
+
+	err := readResults(newReader(), newResultAccumulator(), IBMOptions{MaxResults: 2})
+	assert.Error(err)
+
+	err = readResults(newReader(), newResultAccumulator(), IBMOptions{MaxResults: 3})
+	assert.NoError(err)
+}
+
+func TestResultAccumulatorSkipsRecognizedAudioAfterDrop(t *testing.T) {
+	assert := assert.New(t)
+
+	results := newResultAccumulator()
+	results.add(newTimestampedResult([]ibmWordTimestamp{{"one", 0.0, 1.0}}))
+	interim := newTimestampedResult([]ibmWordTimestamp{{"tw", 1.0, 1.5}})
+	interim.ResultIndex = 1
+	interim.Results[0].Final = false
+	results.add(interim)
+
+	// the new connection recognizes the audio from the start again
+	results.resume()
+	second := newTimestampedResult(
+		[]ibmWordTimestamp{{"one", 0.0, 1.0}},
+		[]ibmWordTimestamp{{"two", 1.0, 2.0}},
+	)
+	results.add(second)
+
+	res := results.result()
+	assert.Len(res.Results, 2)
+	assert.Equal([]string{"one ", "two "}, transcripts(res))
+}
+
+// mockResult returns the JSON of a final result with the given index and
+// words.
+func mockResult(index int, words ...ibmWordTimestamp) string {
+	res := newTimestampedResult(words)
+	res.ResultIndex = index
+	data, _ := json.Marshal(res)
+	return string(data)
+}
+
+// newDroppingIBMServer returns a mock IBM server whose first drops
+// connections close without a close frame after sending the first result. It
+// also returns the number of connections made to it.
+func newDroppingIBMServer(t *testing.T, drops int) (*httptest.Server, *int32) {
+	script := []ibmtest.MockFrame{}
+	for i := 0; i < drops; i++ {
+		script = append(script,
+			ibmtest.MockFrame{JSON: `{"state": "listening"}`},
+			ibmtest.MockFrame{JSON: mockResult(0, ibmWordTimestamp{"one", 0.0, 1.0})},
+			ibmtest.MockFrame{Drop: true},
+		)
+	}
+	script = append(script,
+		ibmtest.MockFrame{JSON: `{"state": "listening"}`},
+		ibmtest.MockFrame{JSON: mockResult(0, ibmWordTimestamp{"one", 0.0, 1.0})},
+		ibmtest.MockFrame{JSON: mockResult(1, ibmWordTimestamp{"two", 1.0, 2.0})},
+		ibmtest.MockFrame{JSON: `{"state": "listening"}`},
+	)
+
+	var connections int32
+	server := ibmtest.NewRecordingMockIBMServer(t, script, func(ibmtest.Recognition) {
+		atomic.AddInt32(&connections, 1)
+	})
+	return server, &connections
+}
+
+func TestTranscribeWithIBMReconnectsAfterAbnormalClosure(t *testing.T) {
+	assert := assert.New(t)
+
+	server, connections := newDroppingIBMServer(t, 1)
+	defer server.Close()
+
+	creds := IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	res, err := TranscribeWithIBMContext(context.Background(), "test.flac", nil, creds, IBMOptions{})
+	assert.NoError(err)
+	if assert.NotNil(res) {
+		assert.Equal([]string{"one ", "two "}, transcripts(res))
+	}
+	assert.Equal(int32(2), atomic.LoadInt32(connections))
+}
+
+func TestTranscribeWithIBMCallsRetryHooks(t *testing.T) {
+	assert := assert.New(t)
+
+	server, _ := newDroppingIBMServer(t, 2)
+	defer server.Close()
+
+	retries := []int{}
+	successes := []int{}
+	opts := IBMOptions{
+		OnRetry: func(attempt int, err error) {
+			assert.Error(err)
+			retries = append(retries, attempt)
+		},
+		OnSuccess: func(attempts int) { successes = append(successes, attempts) },
+	}
+	creds := IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	_, err := TranscribeWithIBMContext(context.Background(), "test.flac", nil, creds, opts)
+	assert.NoError(err)
+	assert.Equal([]int{1, 2}, retries)
+	assert.Equal([]int{3}, successes)
+}
+
+func TestTranscribeFileStopsWhenCancelled(t *testing.T) {
+	assert := assert.New(t)
+
+	// the server reads the request but never sends any results
+	started := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		ws.ReadMessage()
+		close(started)
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	creds := IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	done := make(chan error)
+	go func() {
+		_, err := transcribeFile(ctx, "test.flac", creds, IBMOptions{})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.Equal(context.Canceled, errors.Cause(err))
+	case <-time.After(5 * time.Second):
+		t.Fatal("transcription did not stop when its context was cancelled")
+	}
+}
+
+func TestTranscribeWithIBMRecordsModel(t *testing.T) {
+	assert := assert.New(t)
+
+	server, requests := newRecordingIBMServer(t)
+	defer server.Close()
+
+	creds := IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	res, err := TranscribeWithIBMContext(context.Background(), "test.flac", nil, creds, IBMOptions{Model: "en-GB_NarrowbandModel"})
+	assert.NoError(err)
+	if assert.NotNil(res) {
+		assert.Equal("en-GB_NarrowbandModel", res.Model)
+		assert.Equal("en-GB", res.Language())
+	}
+	assert.Equal("en-GB_NarrowbandModel", (<-requests).Query.Get("model"))
+
+	assert.Equal("", new(IBMResult).Language())
+}
+
+func TestTranscribeWithIBMGivesUpAfterReconnectAttempts(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(attempts int) { ibmReconnectAttempts = attempts }(ibmReconnectAttempts)
+	ibmReconnectAttempts = 2
+
+	server, connections := newDroppingIBMServer(t, 10)
+	defer server.Close()
+
+	creds := IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	_, err := TranscribeWithIBMContext(context.Background(), "test.flac", nil, creds, IBMOptions{})
+	assert.Error(err)
+	assert.Equal(int32(3), atomic.LoadInt32(connections))
+}
+
+func TestReadResultsWritesFinalResultsIncrementally(t *testing.T) {
+	assert := assert.New(t)
+
+	// the stream ends before IBM finishes, like a crash
+	ws := &scriptedReader{messages: []string{
+		`{"state": "listening"}`,
+		`{"result_index": 0, "results": [{"alternatives": [{"transcript": "one "}], "final": true}]}`,
+		`{"result_index": 1, "results": [{"alternatives": [{"transcript": "tw"}], "final": false}]}`,
+		`{"result_index": 1, "results": [{"alternatives": [{"transcript": "two "}], "final": true}]}`,
+		`{"result_index": 2, "results": [{"alternatives": [{"transcript": "thr"}], "final": false}]}`,
+	}}
+	var output bytes.Buffer
+	results := newResultAccumulator()
+	results.output = &output
+	assert.Error(readResults(ws, results, IBMOptions{}))
+	assert.Equal("one\ntwo\n", output.String())
+}
+
+func TestTranscribeWithIBMWritesIncrementalOutput(t *testing.T) {
+	assert := assert.New(t)
+
+	server, _ := newDroppingIBMServer(t, 1)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "transcribe4all")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "transcript.txt")
+
+	creds := IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	_, err = TranscribeWithIBMContext(context.Background(), "test.flac", nil, creds, IBMOptions{IncrementalOutputPath: path})
+	assert.NoError(err)
+	text, err := ioutil.ReadFile(path)
+	assert.NoError(err)
+	// the result received before the reconnect is written once
+	assert.Equal("one\ntwo\n", string(text))
+}
+
+// newRecordingIBMServer returns a mock IBM server which sends each request it
+// receives to requests and transcribes it as "hello".
+func newRecordingIBMServer(t *testing.T) (*httptest.Server, <-chan ibmtest.Recognition) {
+	requests := make(chan ibmtest.Recognition, 1)
+	server := ibmtest.NewRecordingMockIBMServer(t, []ibmtest.MockFrame{
+		{JSON: `{"state": "listening"}`},
+		{JSON: mockResult(0, ibmWordTimestamp{"hello", 0.0, 1.0})},
+		{JSON: `{"state": "listening"}`},
+	}, func(recognition ibmtest.Recognition) {
+		requests <- recognition
+	})
+	return server, requests
+}
+
+func TestTranscribeStdinStreamsAudio(t *testing.T) {
+	assert := assert.New(t)
+
+	server, requests := newRecordingIBMServer(t)
+	defer server.Close()
+
+	audio := bytes.Repeat([]byte("RIFF audio "), 1000)
+	r, w := io.Pipe()
+	go func() {
+		// write in pieces, like a shell pipe
+		for i := 0; i < len(audio); i += 3000 {
+			end := i + 3000
+			if end > len(audio) {
+				end = len(audio)
+			}
+			w.Write(audio[i:end])
+		}
+		w.Close()
+	}()
+	defer func(r io.Reader) { stdin = r }(stdin)
+	stdin = r
+
+	creds := IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	res, err := TranscribeStdin(context.Background(), "audio/wav", creds)
+	assert.NoError(err)
+	if assert.NotNil(res) {
+		assert.Equal([]string{"hello "}, transcripts(res))
+	}
+
+	request := <-requests
+	assert.Equal("audio/wav", request.Start["content-type"])
+	assert.Equal(audio, request.Audio)
+}
+
+func TestTranscribeReaderWithIBMSendsSizedFrames(t *testing.T) {
+	assert := assert.New(t)
+
+	server, requests := newRecordingIBMServer(t)
+	defer server.Close()
+
+	audio := bytes.Repeat([]byte{1}, 2500)
+	creds := IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	_, err := TranscribeReaderWithIBM(context.Background(), bytes.NewReader(audio), nil, creds, IBMOptions{FrameSize: 1000})
+	assert.NoError(err)
+
+	request := <-requests
+	// the last frame holds only the rest of the audio and the empty message
+	// which ends the upload comes last
+	assert.Equal([]int{1000, 1000, 500, 0}, request.Frames)
+	assert.Equal(audio, request.Audio)
+}
+
+func TestTranscribeReaderWithIBMCapsFrameSize(t *testing.T) {
+	assert := assert.New(t)
+
+	server, requests := newRecordingIBMServer(t)
+	defer server.Close()
+
+	audio := bytes.Repeat([]byte{1}, 5000)
+	creds := IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	opts := IBMOptions{FrameSize: 4000, MaxFrameSize: 1200}
+	_, err := TranscribeReaderWithIBM(context.Background(), bytes.NewReader(audio), nil, creds, opts)
+	assert.NoError(err)
+
+	request := <-requests
+	for _, frame := range request.Frames {
+		assert.True(frame <= 1200, "frame of %d bytes", frame)
+	}
+	assert.Equal([]int{1200, 1200, 1200, 1200, 200, 0}, request.Frames)
+	assert.Equal(audio, request.Audio)
+
+	_, err = IBMOptions{MaxFrameSize: ibmMaxFrameSize + 1}.startMessage(nil)
+	assert.Error(err)
+	assert.Equal(ibmMaxFrameSize, IBMOptions{FrameSize: 10 << 20}.frameSize())
+}
+
+func TestReadResultsCollectsWarnings(t *testing.T) {
+	assert := assert.New(t)
+
+	ws := &scriptedReader{messages: []string{
+		`{"state": "listening", "warnings": ["Unknown arguments: smart_formating."]}`,
+		`{"result_index": 0, "results": [{"alternatives": [{"transcript": "hello "}], "final": true}]}`,
+		`{"state": "listening"}`,
+	}}
+	results := newResultAccumulator()
+	assert.NoError(readResults(ws, results, IBMOptions{}))
+	assert.Equal([]string{"Unknown arguments: smart_formating."}, results.result().Warnings)
+}
+
+func TestReadResultsPassesWarningsToOnWarning(t *testing.T) {
+	assert := assert.New(t)
+
+	var logged bytes.Buffer
+	defer log.SetOutput(log.StandardLogger().Out)
+	log.SetOutput(&logged)
+
+	ws := &scriptedReader{messages: []string{
+		`{"state": "listening", "warnings": ["Unknown arguments: smart_formating."]}`,
+		`{"state": "listening"}`,
+	}}
+	warnings := []string{}
+	results := newResultAccumulator()
+	opts := IBMOptions{OnWarning: func(warning string) {
+		warnings = append(warnings, warning)
+	}}
+	assert.NoError(readResults(ws, results, opts))
+	assert.Equal([]string{"Unknown arguments: smart_formating."}, warnings)
+	assert.Equal(warnings, results.result().Warnings)
+	// the warning is not logged
+	assert.NotContains(logged.String(), "smart_formating")
+}
+
+func TestTranscribeFirstPhraseClosesStreamAfterFinalResult(t *testing.T) {
+	assert := assert.New(t)
+
+	server := ibmtest.NewMockIBMServer(t, []ibmtest.MockFrame{
+		{JSON: `{"state": "listening"}`, WhileUploading: true},
+		{JSON: mockResult(0, ibmWordTimestamp{"lights", 0.0, 0.5}, ibmWordTimestamp{"on", 0.5, 1.0}), WhileUploading: true},
+		{JSON: `{"state": "listening"}`},
+	})
+
+	// the audio never ends, like a microphone
+	r, w := io.Pipe()
+	defer w.Close()
+	go w.Write(bytes.Repeat([]byte("fLaC"), 1000))
+
+	creds := IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	phrase, err := TranscribeFirstPhrase(context.Background(), r, creds)
+	assert.NoError(err)
+	assert.Equal("lights on", phrase)
+
+	// Close waits for the server to see that the connection closed
+	closed := make(chan struct{})
+	go func() {
+		server.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Error("the connection to IBM was not closed")
+	}
+}
+
+func TestTranscribeWithIBMFollowsGrowingFile(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(interval time.Duration) { followPollInterval = interval }(followPollInterval)
+	followPollInterval = 10 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "follow")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "live.flac")
+	file, err := os.Create(path)
+	assert.NoError(err)
+	defer file.Close()
+
+	chunk := bytes.Repeat([]byte("fLaC"), 1000)
+	file.Write(chunk)
+	go func() {
+		// keep writing while the upload is in progress
+		for i := 0; i < 4; i++ {
+			time.Sleep(50 * time.Millisecond)
+			file.Write(chunk)
+		}
+	}()
+
+	server, requests := newRecordingIBMServer(t)
+	defer server.Close()
+
+	creds := IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	opts := IBMOptions{Follow: true, FollowTimeout: 300 * time.Millisecond}
+	res, err := TranscribeWithIBMContext(context.Background(), path, nil, creds, opts)
+	assert.NoError(err)
+	if assert.NotNil(res) {
+		assert.Equal([]string{"hello "}, transcripts(res))
+	}
+	assert.Equal(bytes.Repeat(chunk, 5), (<-requests).Audio)
+}
@@ -0,0 +1,186 @@
+// Package ibmtest implements a mock of the IBM Watson Speech to Text
+// websocket API for testing code which transcribes with IBM.
+package ibmtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// MockFrame is a step of the script of a mock IBM server.
+type MockFrame struct {
+	// JSON is sent to the client as a text message.
+	JSON string
+	// Drop closes the connection without a close frame, which the client sees
+	// as an abnormal closure with code 1006. The next connection continues
+	// with the rest of the script.
+	Drop bool
+	// WhileUploading sends the frame as soon as the start message is received,
+	// without waiting for the end of the upload. It only applies to the
+	// frames at the start of the part of the script played by a connection.
+	WhileUploading bool
+	// Delay waits before the frame is played, such as to give the client time
+	// to send keepalives.
+	Delay time.Duration
+}
+
+// Recognition is a recognition request received by a mock IBM server.
+type Recognition struct {
+	// Query is the query of the recognize url, such as the model.
+	Query url.Values
+	// Header is the header of the websocket handshake.
+	Header http.Header
+	// Start is the message which started the request.
+	Start map[string]interface{}
+	// Audio is the uploaded audio.
+	Audio []byte
+	// Frames are the sizes of the binary messages, including the empty
+	// message which ends the upload.
+	Frames []int
+}
+
+// NewMockIBMServer returns a server which behaves like IBM's recognize
+// websocket on any path. For every connection, it reads the
+// start message and the uploaded audio, reporting an error to t if the client
+// sends them out of order, and then plays the script. Use the server's URL as
+// the URL of the IBM credentials.
+func NewMockIBMServer(t testing.TB, script []MockFrame) *httptest.Server {
+	return NewRecordingMockIBMServer(t, script, nil)
+}
+
+// NewRecordingMockIBMServer works like NewMockIBMServer and calls record with
+// every recognition request once its audio is uploaded, before the script is
+// played.
+func NewRecordingMockIBMServer(t testing.TB, script []MockFrame, record func(Recognition)) *httptest.Server {
+	return httptest.NewServer(newMockIBMHandler(t, script, record))
+}
+
+// NewTLSMockIBMServer works like NewMockIBMServer, but serves HTTPS with a
+// self-signed certificate, which clients only accept without verification or
+// with the server's Client.
+func NewTLSMockIBMServer(t testing.TB, script []MockFrame) *httptest.Server {
+	return httptest.NewTLSServer(newMockIBMHandler(t, script, nil))
+}
+
+// newMockIBMHandler returns the handler of a mock IBM server.
+func newMockIBMHandler(t testing.TB, script []MockFrame, record func(Recognition)) http.Handler {
+	var mu sync.Mutex
+	next := 0
+	// nextFrame returns the next frame of the script, or false at its end.
+	nextFrame := func() (MockFrame, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if next == len(script) {
+			return MockFrame{}, false
+		}
+		next++
+		return script[next-1], true
+	}
+	// whileUploading returns the frames at the next position of the script
+	// which are sent while the audio is uploaded.
+	whileUploading := func() []MockFrame {
+		mu.Lock()
+		defer mu.Unlock()
+		frames := []MockFrame{}
+		for next < len(script) && script[next].WhileUploading {
+			frames = append(frames, script[next])
+			next++
+		}
+		return frames
+	}
+
+	upgrader := websocket.Upgrader{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("websocket handshake failed: %v", err)
+			return
+		}
+		defer ws.Close()
+
+		recognition := Recognition{Query: r.URL.Query(), Header: r.Header}
+		if !readRequest(t, ws, &recognition, whileUploading) {
+			return
+		}
+		if record != nil {
+			record(recognition)
+		}
+
+		// the client may only send keepalives while results are sent
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				messageType, _, err := ws.ReadMessage()
+				if err != nil {
+					return
+				}
+				if messageType == websocket.BinaryMessage {
+					t.Errorf("audio was sent after the end of the upload")
+				}
+			}
+		}()
+
+		for {
+			frame, ok := nextFrame()
+			if !ok {
+				// wait for the client to answer the close message, so that a
+				// keepalive sent meanwhile does not hit a closed connection
+				ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+				select {
+				case <-closed:
+				case <-time.After(time.Second):
+				}
+				return
+			}
+			time.Sleep(frame.Delay)
+			if frame.Drop {
+				return
+			}
+			if err := ws.WriteMessage(websocket.TextMessage, []byte(frame.JSON)); err != nil {
+				return
+			}
+		}
+	})
+}
+
+// readRequest reads the start message and the audio of a recognition request
+// into recognition, sending the frames returned by whileUploading after the
+// start message. It returns false if the client disconnected or sent the
+// messages out of order.
+func readRequest(t testing.TB, ws *websocket.Conn, recognition *Recognition, whileUploading func() []MockFrame) bool {
+	if err := ws.ReadJSON(&recognition.Start); err != nil {
+		t.Errorf("expected a start message: %v", err)
+		return false
+	}
+	if recognition.Start["action"] != "start" {
+		t.Errorf("expected a start message but got %v", recognition.Start)
+		return false
+	}
+	for _, frame := range whileUploading() {
+		if err := ws.WriteMessage(websocket.TextMessage, []byte(frame.JSON)); err != nil {
+			return false
+		}
+	}
+	for {
+		messageType, data, err := ws.ReadMessage()
+		if err != nil {
+			return false
+		}
+		if messageType != websocket.BinaryMessage {
+			t.Errorf("expected audio but got %q", data)
+			return false
+		}
+		recognition.Frames = append(recognition.Frames, len(data))
+		if len(data) == 0 {
+			return true
+		}
+		recognition.Audio = append(recognition.Audio, data...)
+	}
+}
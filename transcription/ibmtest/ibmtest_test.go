@@ -0,0 +1,38 @@
+package ibmtest_test
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hack4impact/transcribe4all/transcription"
+	"github.com/hack4impact/transcribe4all/transcription/ibmtest"
+)
+
+func TestMockIBMServerWithTranscribeWithIBM(t *testing.T) {
+	assert := assert.New(t)
+
+	recognitions := make(chan ibmtest.Recognition, 1)
+	server := ibmtest.NewRecordingMockIBMServer(t, []ibmtest.MockFrame{
+		{JSON: `{"state": "listening"}`},
+		{JSON: `{"result_index": 0, "results": [{"alternatives": [{"transcript": "hello world ", "confidence": 0.9}], "final": true}]}`},
+		{JSON: `{"state": "listening"}`},
+	}, func(recognition ibmtest.Recognition) {
+		recognitions <- recognition
+	})
+	defer server.Close()
+
+	creds := transcription.IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	res, err := transcription.TranscribeWithIBMContext(context.Background(), "../test.flac", []string{"world"}, creds, transcription.IBMOptions{})
+	assert.NoError(err)
+	assert.Equal("hello world", transcription.GetTranscription([]*transcription.IBMResult{res}).Transcript)
+
+	audio, err := ioutil.ReadFile("../test.flac")
+	assert.NoError(err)
+	recognition := <-recognitions
+	assert.Equal(audio, recognition.Audio)
+	assert.Equal("en-US_BroadbandModel", recognition.Query.Get("model"))
+	assert.Equal([]interface{}{"world"}, recognition.Start["keywords"])
+}
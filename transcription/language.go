@@ -0,0 +1,80 @@
+package transcription
+
+import (
+	"strings"
+	"unicode"
+)
+
+// languageTrigrams are the most common trigrams of each language guessed by
+// GuessLanguageFromText, most common first. A space stands for the start or
+// end of a word.
+var languageTrigrams = map[string][]string{
+	"en": {
+		" th", "the", "he ", "ed ", " an", "nd ", "and", "ing", "ng ", " to",
+		"to ", " of", "of ", "ion", " in", "er ", "is ", " is", "at ", "ent",
+		"re ", "her", "on ", "tha", "hat", "es ", " wa", "for", " it", "it ",
+	},
+	"es": {
+		"de ", " de", " la", "la ", "os ", "el ", " el", "es ", " qu", "que",
+		"ue ", " co", "ent", "as ", "en ", " en", "ión", "ció", "ón ", "ado",
+		"del", " lo", "los", "nte", "con", "ara", " pa", "par", " es", "una",
+	},
+	"fr": {
+		" de", "es ", "de ", "le ", " le", "ent", " la", "la ", "ion", "nt ",
+		" et", "et ", "les", " pa", "que", " qu", "ue ", "re ", "des", "ne ",
+		"tio", " co", "men", "ait", " un", "une", "our", " ce", "est", " es",
+	},
+	"de": {
+		"en ", "er ", " de", "der", "ch ", "ich", "ein", "sch", "die", " di",
+		"ie ", "cht", "nd ", "und", " un", "ung", "gen", "in ", " ei", "den",
+		"te ", "es ", " da", "das", "ten", "ine", "ver", " ve", " zu", "ist",
+	},
+	"it": {
+		" di", "di ", "che", " ch", "he ", "to ", "la ", " la", "re ", "one",
+		"ell", " co", "del", "lla", "ent", " il", "il ", "zio", "ion", "no ",
+		"ato", "per", " pe", "nte", "ne ", " in", "le ", "ti ", "non", " no",
+	},
+	"pt": {
+		" de", "de ", "os ", " qu", "que", "ue ", "do ", " do", "da ", " da",
+		"ão ", "ção", " co", "ent", "es ", "com", "nte", " pa", "par", " se",
+		"as ", "em ", " em", "ado", "or ", "ra ", " um", "uma", "ões", "não",
+	},
+}
+
+// GuessLanguageFromText returns the ISO 639-1 code of the language text is
+// most likely written in, such as en, or an empty string if it cannot tell.
+// It compares the trigrams of text with those most common in English,
+// Spanish, French, German, Italian and Portuguese, so it needs a sentence or
+// more to be reliable. Compare it with IBMResult.Language to find transcripts
+// made with a model of the wrong language.
+func GuessLanguageFromText(text string) string {
+	counts := trigramCounts(text)
+	best, bestScore := "", 0
+	for language, trigrams := range languageTrigrams {
+		// a trigram scores more the more common it is in the language
+		score := 0
+		for rank, trigram := range trigrams {
+			score += counts[trigram] * (len(trigrams) - rank)
+		}
+		if score > bestScore || (score == bestScore && score > 0 && language < best) {
+			best, bestScore = language, score
+		}
+	}
+	return best
+}
+
+// trigramCounts returns how often each trigram of the words of text occurs.
+// Letters are lowercased, and each word is padded with a space on both sides.
+func trigramCounts(text string) map[string]int {
+	counts := map[string]int{}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, word := range words {
+		runes := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			counts[string(runes[i:i+3])]++
+		}
+	}
+	return counts
+}
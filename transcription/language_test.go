@@ -0,0 +1,17 @@
+package transcription
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuessLanguageFromText(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("en", GuessLanguageFromText("In the mid sixties the airline industry had a problem, and it was in the planes."))
+	assert.Equal("es", GuessLanguageFromText("En los años sesenta la industria de las aerolíneas tenía un problema con los aviones."))
+	assert.Equal("de", GuessLanguageFromText("In den sechziger Jahren hatte die Luftfahrtindustrie ein Problem, und es lag an den Flugzeugen."))
+	assert.Equal("", GuessLanguageFromText(""))
+	assert.Equal("", GuessLanguageFromText("42"))
+}
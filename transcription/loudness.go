@@ -0,0 +1,35 @@
+package transcription
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/juju/errors"
+)
+
+// NormalizeLoudness writes the audio of the file at filePath, normalized to an
+// integrated loudness of targetLUFS with ffmpeg's loudnorm filter, to a
+// temporary file in the same format and returns its path. The caller should
+// remove the file. targetLUFS must be between -70 and -5, and -16 is common
+// for speech. Quiet recordings transcribe better once normalized.
+func NormalizeLoudness(filePath string, targetLUFS float64) (string, error) {
+	if targetLUFS < -70 || targetLUFS > -5 {
+		return "", errors.Errorf("target loudness must be between -70 and -5 LUFS, got %v", targetLUFS)
+	}
+	file, err := ioutil.TempFile("", "loudnorm-*"+filepath.Ext(filePath))
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	file.Close()
+
+	out, err := runFFmpeg("-y", "-i", filePath,
+		"-af", "loudnorm=I="+strconv.FormatFloat(targetLUFS, 'f', -1, 64)+":TP=-1.5:LRA=11",
+		file.Name())
+	if err != nil {
+		os.Remove(file.Name())
+		return "", errors.New(err.Error() + "\nCommand Output:" + string(out))
+	}
+	return file.Name(), nil
+}
@@ -0,0 +1,74 @@
+package transcription
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeLoudnessPassesLoudnormFilterToFFmpeg(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(run func(...string) ([]byte, error)) { runFFmpeg = run }(runFFmpeg)
+	var calls [][]string
+	runFFmpeg = func(args ...string) ([]byte, error) {
+		calls = append(calls, args)
+		return nil, nil
+	}
+
+	path, err := NormalizeLoudness("audio.flac", -16)
+	assert.NoError(err)
+	defer os.Remove(path)
+	assert.True(strings.HasSuffix(path, ".flac"))
+	assert.Equal([][]string{
+		{"-y", "-i", "audio.flac", "-af", "loudnorm=I=-16:TP=-1.5:LRA=11", path},
+	}, calls)
+
+	_, err = NormalizeLoudness("audio.flac", 0)
+	assert.Error(err)
+	assert.Len(calls, 1)
+}
+
+func TestNormalizeLoudnessRemovesFileIfFFmpegFails(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(run func(...string) ([]byte, error)) { runFFmpeg = run }(runFFmpeg)
+	var out string
+	runFFmpeg = func(args ...string) ([]byte, error) {
+		out = args[len(args)-1]
+		return []byte("invalid data"), errors.New("exit status 1")
+	}
+
+	_, err := NormalizeLoudness("audio.flac", -16)
+	assert.Error(err)
+	_, err = os.Stat(out)
+	assert.True(os.IsNotExist(err))
+}
+
+func TestTranscribeWithIBMNormalizesLoudness(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(run func(...string) ([]byte, error)) { runFFmpeg = run }(runFFmpeg)
+	var normalized string
+	runFFmpeg = func(args ...string) ([]byte, error) {
+		normalized = args[len(args)-1]
+		return nil, ioutil.WriteFile(normalized, []byte("normalized audio"), 0644)
+	}
+
+	server, requests := newRecordingIBMServer(t)
+	defer server.Close()
+
+	creds := IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	_, err := TranscribeWithIBMContext(context.Background(), "test.flac", nil, creds, IBMOptions{LoudnessTarget: -16})
+	assert.NoError(err)
+	assert.Equal("normalized audio", string((<-requests).Audio))
+
+	// the normalized file is removed
+	_, err = os.Stat(normalized)
+	assert.True(os.IsNotExist(err))
+}
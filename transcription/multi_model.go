@@ -0,0 +1,124 @@
+package transcription
+
+import (
+	"context"
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/juju/errors"
+)
+
+// TranscribeMultiModel transcribes the file at filePath once with each of
+// models, such as en-US_BroadbandModel and es-ES_BroadbandModel, and merges the
+// results. Where the results of several models cover the same time, the
+// segments with the highest confidence are kept. This helps with recordings
+// in which several languages are spoken. It returns a *TranscribeError if
+// transcription fails.
+func TranscribeMultiModel(filePath string, creds IBMCredentials, models []string) (*IBMResult, error) {
+	if len(models) == 0 {
+		return nil, &TranscribeError{Path: filePath, Err: errors.New("no models given")}
+	}
+	results := []*IBMResult{}
+	for _, model := range models {
+		res, err := TranscribeWithIBMContext(context.Background(), filePath, nil, creds, IBMOptions{Model: model})
+		if err != nil {
+			return nil, annotatePhase(err, "could not transcribe with model %s", model)
+		}
+		results = append(results, res)
+	}
+	return mergeByConfidence(results), nil
+}
+
+// TranscribeWithFallback transcribes the file at filePath with each of models
+// in turn until one returns a transcript whose average segment confidence is
+// at least minConfidence. Empty transcripts never qualify. Models which fail
+// are skipped, and a *TranscribeError is returned if no model qualifies.
+func TranscribeWithFallback(filePath string, creds IBMCredentials, models []string, minConfidence float64) (*IBMResult, error) {
+	if len(models) == 0 {
+		return nil, &TranscribeError{Path: filePath, Err: errors.New("no models given")}
+	}
+	for _, model := range models {
+		res, err := transcribeFile(context.Background(), filePath, creds, IBMOptions{Model: model})
+		if err != nil {
+			log.Warnf("Could not transcribe %s with model %s, trying the next model: %v", filePath, model, err)
+			continue
+		}
+		confidences := SegmentConfidences(res)
+		if len(confidences) == 0 {
+			log.Warnf("Model %s found no speech in %s, trying the next model", model, filePath)
+			continue
+		}
+		total := 0.0
+		for _, confidence := range confidences {
+			total += confidence
+		}
+		if average := total / float64(len(confidences)); average < minConfidence {
+			log.Warnf("Model %s transcribed %s with confidence %.2f, trying the next model", model, filePath, average)
+			continue
+		}
+		return res, nil
+	}
+	return nil, &TranscribeError{Path: filePath, Err: errors.Errorf("no model reached a confidence of at least %.2f", minConfidence)}
+}
+
+// timedSegment is a segment of a result with the time it spans.
+type timedSegment struct {
+	result ibmResultField
+	start  float64
+	end    float64
+}
+
+// mergeByConfidence merges the segments of results which were recognized from
+// the same audio. Segments are kept in order of confidence unless they
+// overlap a segment which was already kept. Segments without timestamps
+// cannot be placed in time and are dropped, as are speaker labels.
+func mergeByConfidence(results []*IBMResult) *IBMResult {
+	candidates := []timedSegment{}
+	for _, res := range results {
+		for _, subResult := range res.Results {
+			if len(subResult.Alternatives) == 0 || len(subResult.Alternatives[0].Timestamps) == 0 {
+				continue
+			}
+			candidates = append(candidates, timedSegment{
+				result: subResult,
+				start:  resultStart(subResult),
+				end:    resultEnd(subResult),
+			})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].result.Alternatives[0].OverallConfidence > candidates[j].result.Alternatives[0].OverallConfidence
+	})
+
+	kept := []timedSegment{}
+	for _, candidate := range candidates {
+		overlaps := false
+		for _, segment := range kept {
+			if candidate.start < segment.end && segment.start < candidate.end {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			kept = append(kept, candidate)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].start < kept[j].start
+	})
+
+	merged := &IBMResult{Results: make([]ibmResultField, 0, len(kept))}
+	for _, segment := range kept {
+		merged.Results = append(merged.Results, segment.result)
+	}
+	return merged
+}
+
+// resultStart returns the start, in seconds, of the first word of result.
+func resultStart(result ibmResultField) float64 {
+	if len(result.Alternatives) == 0 || len(result.Alternatives[0].Timestamps) == 0 {
+		return 0
+	}
+	start, _ := result.Alternatives[0].Timestamps[0][1].(float64)
+	return start
+}
@@ -0,0 +1,72 @@
+package transcription
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeByConfidence(t *testing.T) {
+	assert := assert.New(t)
+
+	english := newTimestampedResult(
+		[]ibmWordTimestamp{{"hello", 0.0, 1.0}},
+		[]ibmWordTimestamp{{"or", 1.0, 2.0}, {"la", 2.0, 2.5}},
+	)
+	english.Results[0].Alternatives[0].OverallConfidence = 0.9
+	english.Results[1].Alternatives[0].OverallConfidence = 0.3
+
+	spanish := newTimestampedResult(
+		[]ibmWordTimestamp{{"el", 0.0, 0.5}, {"lo", 0.5, 1.0}},
+		[]ibmWordTimestamp{{"hola", 1.2, 2.4}},
+	)
+	spanish.Results[0].Alternatives[0].OverallConfidence = 0.2
+	spanish.Results[1].Alternatives[0].OverallConfidence = 0.8
+
+	merged := mergeByConfidence([]*IBMResult{english, spanish})
+	assert.Equal([]string{"hello ", "hola "}, transcripts(merged))
+}
+
+func TestTranscribeMultiModelRequiresModels(t *testing.T) {
+	_, err := TranscribeMultiModel("test.flac", IBMCredentials{}, nil)
+	assert.Error(t, err)
+}
+
+// stubModels replaces transcribeFile with a stub returning results[model].
+// The returned function restores it.
+func stubModels(results map[string]*IBMResult, tried *[]string) func() {
+	old := transcribeFile
+	transcribeFile = func(ctx context.Context, filePath string, creds IBMCredentials, opts IBMOptions) (*IBMResult, error) {
+		*tried = append(*tried, opts.Model)
+		if res, ok := results[opts.Model]; ok {
+			return res, nil
+		}
+		return nil, errors.New("unknown model")
+	}
+	return func() {
+		transcribeFile = old
+	}
+}
+
+func TestTranscribeWithFallback(t *testing.T) {
+	assert := assert.New(t)
+
+	mumbled := newConfidenceResult(map[string]float64{"mumble": 0.2, "hello": 0.5}, "mumble", "hello")
+	clear := newConfidenceResult(map[string]float64{"hello": 0.9}, "hello")
+	tried := []string{}
+	defer stubModels(map[string]*IBMResult{
+		"en-US_BroadbandModel":  mumbled,
+		"en-US_NarrowbandModel": clear,
+		"en-GB_BroadbandModel":  new(IBMResult),
+	}, &tried)()
+
+	res, err := TranscribeWithFallback("audio.flac", IBMCredentials{}, []string{"unknown", "en-GB_BroadbandModel", "en-US_BroadbandModel", "en-US_NarrowbandModel", "en-US_Telephony"}, 0.6)
+	assert.NoError(err)
+	assert.Equal(clear, res)
+	assert.Equal([]string{"unknown", "en-GB_BroadbandModel", "en-US_BroadbandModel", "en-US_NarrowbandModel"}, tried)
+
+	_, err = TranscribeWithFallback("audio.flac", IBMCredentials{}, []string{"en-US_BroadbandModel"}, 0.6)
+	assert.Error(err)
+}
@@ -0,0 +1,73 @@
+package transcription
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/juju/errors"
+)
+
+var (
+	// transcribeFile transcribes the file at filePath. It is a variable so
+	// that tests can stub it.
+	transcribeFile = func(ctx context.Context, filePath string, creds IBMCredentials, opts IBMOptions) (*IBMResult, error) {
+		return TranscribeWithIBMContext(ctx, filePath, []string{}, creds, opts)
+	}
+	// sendEmail is SendEmailContext. It is a variable so that tests can stub
+	// it.
+	sendEmail = SendEmailContext
+)
+
+// TranscribeAndNotify transcribes the file at filePath with IBM and emails the
+// transcript to the addresses in to. If transcription fails, the failure is
+// emailed instead. Both steps give up once ctx is done. The returned error is
+// a *TranscribeError or an *EmailError, or combines both if both steps fail.
+func TranscribeAndNotify(ctx context.Context, filePath string, creds IBMCredentials, email EmailConfig, to []string) error {
+	return transcribeAndNotify(ctx, filePath, creds, IBMOptions{}, email, to)
+}
+
+func transcribeAndNotify(ctx context.Context, filePath string, creds IBMCredentials, opts IBMOptions, email EmailConfig, to []string) error {
+	name := filepath.Base(filePath)
+
+	if err := ctx.Err(); err != nil {
+		return errors.Trace(err)
+	}
+	result, transcribeErr := transcribeFile(ctx, filePath, creds, opts)
+	if transcribeErr != nil {
+		if _, ok := transcribeErr.(*TranscribeError); !ok {
+			transcribeErr = &TranscribeError{Path: filePath, Err: transcribeErr}
+		}
+	}
+
+	var subject, body string
+	if transcribeErr != nil {
+		subject = fmt.Sprintf("Transcription of %s Failed", name)
+		body = "The error message is below." + "\n\n" + transcribeErr.Error()
+	} else {
+		subject = fmt.Sprintf("Transcription of %s Complete", name)
+		body = "The transcript is below." + "\n\n" + GetTranscription([]*IBMResult{result}).Transcript
+	}
+	emailErr := sendEmail(ctx, email.Username, email.Password, email.Host, email.Port, to, subject, body)
+	if emailErr != nil {
+		if _, ok := emailErr.(*EmailError); !ok {
+			emailErr = &EmailError{To: to, Err: emailErr}
+		}
+	}
+
+	return combineErrors(transcribeErr, emailErr)
+}
+
+// combineErrors returns an error describing both transcribeErr and emailErr,
+// either of which may be nil.
+func combineErrors(transcribeErr, emailErr error) error {
+	switch {
+	case transcribeErr != nil && emailErr != nil:
+		return phaseErrors{transcribeErr, emailErr}
+	case transcribeErr != nil:
+		return transcribeErr
+	case emailErr != nil:
+		return emailErr
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package transcription
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubNotify replaces transcribeFile and sendEmail with stubs returning the
+// given errors. The returned function restores them.
+func stubNotify(transcribeErr, emailErr error, transcribed *bool, sentBody *string) func() {
+	oldTranscribe, oldSend := transcribeFile, sendEmail
+	transcribeFile = func(ctx context.Context, filePath string, creds IBMCredentials, opts IBMOptions) (*IBMResult, error) {
+		*transcribed = true
+		if transcribeErr != nil {
+			return nil, transcribeErr
+		}
+		return newTranscriptResult(0, true, "hello world "), nil
+	}
+	sendEmail = func(ctx context.Context, username, password, host string, port int, to []string, subject, body string) error {
+		*sentBody = body
+		return emailErr
+	}
+	return func() {
+		transcribeFile, sendEmail = oldTranscribe, oldSend
+	}
+}
+
+func TestTranscribeAndNotify(t *testing.T) {
+	assert := assert.New(t)
+
+	var transcribed bool
+	var body string
+	defer stubNotify(nil, nil, &transcribed, &body)()
+
+	err := TranscribeAndNotify(context.Background(), "audio.flac", IBMCredentials{}, EmailConfig{}, []string{"to@email.com"})
+	assert.NoError(err)
+	assert.True(transcribed)
+	assert.True(strings.Contains(body, "hello world"))
+}
+
+func TestTranscribeAndNotifyPropagatesErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	var transcribed bool
+	var body string
+	restore := stubNotify(errors.New("ibm is down"), nil, &transcribed, &body)
+	err := TranscribeAndNotify(context.Background(), "audio.flac", IBMCredentials{}, EmailConfig{}, []string{"to@email.com"})
+	restore()
+	assert.Error(err)
+	assert.True(strings.Contains(err.Error(), "ibm is down"))
+	assert.True(strings.Contains(body, "ibm is down"))
+
+	restore = stubNotify(errors.New("ibm is down"), errors.New("smtp is down"), &transcribed, &body)
+	err = TranscribeAndNotify(context.Background(), "audio.flac", IBMCredentials{}, EmailConfig{}, []string{"to@email.com"})
+	restore()
+	assert.True(strings.Contains(err.Error(), "ibm is down"))
+	assert.True(strings.Contains(err.Error(), "smtp is down"))
+
+	restore = stubNotify(nil, errors.New("smtp is down"), &transcribed, &body)
+	err = TranscribeAndNotify(context.Background(), "audio.flac", IBMCredentials{}, EmailConfig{}, []string{"to@email.com"})
+	restore()
+	assert.True(strings.Contains(err.Error(), "smtp is down"))
+}
+
+func TestTranscribeAndNotifyStopsSendingWhenCancelled(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newMockSMTPServer(t)
+	defer server.Close()
+	server.dataReply = func(message string) string {
+		time.Sleep(2 * time.Second)
+		return ""
+	}
+	defer func(old func(context.Context, string, IBMCredentials, IBMOptions) (*IBMResult, error)) {
+		transcribeFile = old
+	}(transcribeFile)
+	transcribeFile = func(ctx context.Context, filePath string, creds IBMCredentials, opts IBMOptions) (*IBMResult, error) {
+		return newTranscriptResult(0, true, "hello world "), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := TranscribeAndNotify(ctx, "audio.flac", IBMCredentials{}, server.config(), []string{"to@email.com"})
+	assert.True(time.Since(start) < time.Second)
+	var emailErr *EmailError
+	if assert.True(errors.As(err, &emailErr)) {
+		assert.Equal(context.DeadlineExceeded, emailErr.Cause())
+	}
+}
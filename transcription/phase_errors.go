@@ -0,0 +1,130 @@
+package transcription
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// The errors below tell which phase of a transcription failed. Use errors.As
+// of the standard library to tell them apart. errors.As cannot see through
+// errors.Trace or errors.Annotate, so they are traced with tracePhase and
+// annotated with annotatePhase instead. errors.Cause of
+// github.com/juju/errors still returns the underlying cause.
+
+// DownloadError is returned when downloading audio fails.
+type DownloadError struct {
+	URL string
+	Err error
+}
+
+func (e *DownloadError) Error() string {
+	return fmt.Sprintf("downloading %s failed: %v", e.URL, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *DownloadError) Unwrap() error { return e.Err }
+
+// Cause returns the cause of the underlying error.
+func (e *DownloadError) Cause() error { return errors.Cause(e.Err) }
+
+// UploadError is returned when uploading a file to storage, such as S3 or
+// Backblaze, fails.
+type UploadError struct {
+	Path string
+	Err  error
+}
+
+func (e *UploadError) Error() string {
+	return fmt.Sprintf("uploading %s failed: %v", e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *UploadError) Unwrap() error { return e.Err }
+
+// Cause returns the cause of the underlying error.
+func (e *UploadError) Cause() error { return errors.Cause(e.Err) }
+
+// TranscribeError is returned when transcribing audio fails. Path is empty if
+// the audio was not read from a file.
+type TranscribeError struct {
+	Path string
+	Err  error
+}
+
+func (e *TranscribeError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("transcription failed: %v", e.Err)
+	}
+	return fmt.Sprintf("transcription of %s failed: %v", e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *TranscribeError) Unwrap() error { return e.Err }
+
+// Cause returns the cause of the underlying error.
+func (e *TranscribeError) Cause() error { return errors.Cause(e.Err) }
+
+// EmailError is returned when sending an email fails.
+type EmailError struct {
+	To  []string
+	Err error
+}
+
+func (e *EmailError) Error() string {
+	return fmt.Sprintf("sending email to %s failed: %v", strings.Join(e.To, ", "), e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *EmailError) Unwrap() error { return e.Err }
+
+// Cause returns the cause of the underlying error.
+func (e *EmailError) Cause() error { return errors.Cause(e.Err) }
+
+// tracePhase works like errors.Trace, recording where it is called for
+// errors.ErrorStack, but errors.As still finds err, such as a *DownloadError,
+// through the trace.
+func tracePhase(err error) error {
+	if err == nil {
+		return nil
+	}
+	traced := &phaseTrace{Err: errors.NewErrWithCause(err, ""), phase: err}
+	traced.SetLocation(1)
+	return traced
+}
+
+// annotatePhase works like errors.Annotatef, but errors.As still finds err
+// through the annotation, like tracePhase.
+func annotatePhase(err error, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	annotated := &phaseTrace{Err: errors.NewErrWithCause(err, format, args...), phase: err}
+	annotated.SetLocation(1)
+	return annotated
+}
+
+// phaseTrace is an error traced by tracePhase or annotatePhase.
+type phaseTrace struct {
+	errors.Err
+	phase error
+}
+
+// Unwrap returns the traced error.
+func (e *phaseTrace) Unwrap() error { return e.phase }
+
+// phaseErrors are the errors of several phases which failed, such as a
+// transcription and the email reporting it. errors.As finds each of them.
+type phaseErrors []error
+
+func (e phaseErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns the errors of the phases.
+func (e phaseErrors) Unwrap() []error { return e }
@@ -0,0 +1,185 @@
+package transcription
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hack4impact/transcribe4all/transcription/ibmtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadFailureIsDownloadError(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	err := DownloadFileWithOptions(server.URL, filepath.Join(os.TempDir(), "missing.flac"), DownloadOptions{})
+	var downloadErr *DownloadError
+	if assert.True(errors.As(err, &downloadErr)) {
+		assert.Equal(server.URL, downloadErr.URL)
+	}
+}
+
+func TestUploadFailureIsUploadError(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	filePath := writeTempFile(t, []byte("small"))
+	defer os.Remove(filePath)
+
+	cfg := S3Config{Region: "us-east-1", AccessKeyID: "key", SecretAccessKey: "secret", Endpoint: server.URL}
+	_, err := UploadFileToS3(context.Background(), filePath, "bucket", "small.flac", cfg)
+	var uploadErr *UploadError
+	if assert.True(errors.As(err, &uploadErr)) {
+		assert.Equal(filePath, uploadErr.Path)
+	}
+}
+
+func TestTranscriptionFailureIsTranscribeError(t *testing.T) {
+	assert := assert.New(t)
+
+	server := ibmtest.NewMockIBMServer(t, []ibmtest.MockFrame{
+		{JSON: `{"state": "listening"}`},
+		{JSON: `{"error": "unable to transcode data stream"}`},
+	})
+	defer server.Close()
+
+	creds := IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	_, err := TranscribeWithIBMContext(context.Background(), "test.flac", nil, creds, IBMOptions{})
+	var transcribeErr *TranscribeError
+	if assert.True(errors.As(err, &transcribeErr)) {
+		assert.Equal("test.flac", transcribeErr.Path)
+		assert.Contains(err.Error(), "unable to transcode data stream")
+	}
+}
+
+func TestEveryTranscriptionReturnsTranscribeError(t *testing.T) {
+	audio, err := ioutil.ReadFile("test.flac")
+	if !assert.NoError(t, err) {
+		return
+	}
+	transcriptions := map[string]func(creds IBMCredentials) error{
+		"TranscribeWithIBMContext": func(creds IBMCredentials) error {
+			_, err := TranscribeWithIBMContext(context.Background(), "test.flac", nil, creds, IBMOptions{})
+			return err
+		},
+		"TranscribeReaderWithIBM": func(creds IBMCredentials) error {
+			_, err := TranscribeReaderWithIBM(context.Background(), bytes.NewReader(audio), nil, creds, IBMOptions{})
+			return err
+		},
+		"TranscribeFileWithIBM": func(creds IBMCredentials) error {
+			f, err := testAudioFS.Open("test.flac")
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = TranscribeFileWithIBM(context.Background(), f, "audio/flac", creds)
+			return err
+		},
+		"TranscribeStdin": func(creds IBMCredentials) error {
+			defer func(r io.Reader) { stdin = r }(stdin)
+			stdin = bytes.NewReader(audio)
+			_, err := TranscribeStdin(context.Background(), "audio/flac", creds)
+			return err
+		},
+		"TranscribeDataURL": func(creds IBMCredentials) error {
+			dataURL := "data:audio/flac;base64," + base64.StdEncoding.EncodeToString(audio)
+			_, err := TranscribeDataURL(context.Background(), dataURL, creds)
+			return err
+		},
+		"TranscribeFirstPhrase": func(creds IBMCredentials) error {
+			_, err := TranscribeFirstPhrase(context.Background(), bytes.NewReader(audio), creds)
+			return err
+		},
+		"TranscribeMultiModel": func(creds IBMCredentials) error {
+			_, err := TranscribeMultiModel("test.flac", creds, []string{defaultIBMModel})
+			return err
+		},
+		"TranscribeWithFallback": func(creds IBMCredentials) error {
+			_, err := TranscribeWithFallback("test.flac", creds, []string{defaultIBMModel}, 0.5)
+			return err
+		},
+		"TranscribeBatch": func(creds IBMCredentials) error {
+			_, err := TranscribeBatch(context.Background(), []string{"test.flac"}, creds, BatchOptions{})
+			return err
+		},
+	}
+	for name, transcribe := range transcriptions {
+		server := ibmtest.NewMockIBMServer(t, []ibmtest.MockFrame{
+			{JSON: `{"state": "listening"}`},
+			{JSON: `{"error": "unable to transcode data stream"}`},
+		})
+		err := transcribe(IBMCredentials{Username: "user", Password: "pass", URL: server.URL})
+		server.Close()
+		var transcribeErr *TranscribeError
+		assert.True(t, errors.As(err, &transcribeErr), "%s returned %v", name, err)
+	}
+}
+
+func TestEmailFailureIsEmailError(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newMockSMTPServer(t)
+	defer server.Close()
+	server.rcptReply = func(addr string) string {
+		return "550 No such user"
+	}
+
+	cfg := server.config()
+	err := SendEmail(cfg.Username, cfg.Password, cfg.Host, cfg.Port, []string{"nobody@email.com"}, "subject", "body")
+	var emailErr *EmailError
+	if assert.True(errors.As(err, &emailErr)) {
+		assert.Equal([]string{"nobody@email.com"}, emailErr.To)
+	}
+	// the cause is still the reply of the server
+	var protoErr *textproto.Error
+	assert.True(errors.As(emailErr.Cause(), &protoErr))
+	assert.True(isPermanentEmailError(err))
+}
+
+func TestTranscribeAndNotifyReportsEachPhase(t *testing.T) {
+	assert := assert.New(t)
+
+	var transcribed bool
+	var body string
+	defer stubNotify(errors.New("ibm is down"), errors.New("smtp is down"), &transcribed, &body)()
+
+	err := TranscribeAndNotify(context.Background(), "audio.flac", IBMCredentials{}, EmailConfig{}, []string{"to@email.com"})
+	var transcribeErr *TranscribeError
+	var emailErr *EmailError
+	assert.True(errors.As(err, &transcribeErr))
+	assert.True(errors.As(err, &emailErr))
+}
+
+func TestTracePhaseKeepsStackAndPhase(t *testing.T) {
+	assert := assert.New(t)
+
+	err := tracePhase(&DownloadError{URL: "http://example.com/audio.flac", Err: errors.New("not found")})
+	var downloadErr *DownloadError
+	if assert.True(errors.As(err, &downloadErr)) {
+		assert.Equal("http://example.com/audio.flac", downloadErr.URL)
+	}
+	assert.Equal("downloading http://example.com/audio.flac failed: not found", err.Error())
+
+	// the trace is the last line of the stack
+	stack := err.(interface{ StackTrace() []string }).StackTrace()
+	if assert.Len(stack, 2) {
+		assert.Contains(stack[1], "phase_errors_test.go:")
+	}
+	assert.Nil(tracePhase(nil))
+}
@@ -0,0 +1,59 @@
+package transcription
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+
+	"github.com/juju/errors"
+)
+
+// proxy returns the proxy for a request to IBM or any other service. It
+// defaults to the proxy given by the HTTPS_PROXY and HTTP_PROXY environment
+// variables.
+var proxy = http.ProxyFromEnvironment
+
+var (
+	// httpClient makes every outbound HTTP request, through proxy.
+	httpClient = newProxyClient()
+	// insecureHTTPClient is like httpClient, but does not verify TLS
+	// certificates. It is only used if an option asks for it.
+	insecureHTTPClient = newInsecureProxyClient()
+)
+
+func newProxyClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = currentProxy
+	return &http.Client{Transport: transport}
+}
+
+func newInsecureProxyClient() *http.Client {
+	client := newProxyClient()
+	client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	return client
+}
+
+// currentProxy calls proxy, so that clients see calls to SetProxy.
+func currentProxy(request *http.Request) (*url.URL, error) {
+	return proxy(request)
+}
+
+// SetProxy sends every outbound request, including the websocket to IBM,
+// through the proxy at proxyURL, such as http://proxy.example.com:3128. An
+// empty proxyURL goes back to using the proxy of the environment. It should
+// be called before any requests are made.
+func SetProxy(proxyURL string) error {
+	if proxyURL == "" {
+		proxy = http.ProxyFromEnvironment
+		return nil
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return errors.Errorf("proxy url %q needs a scheme and a host", proxyURL)
+	}
+	proxy = http.ProxyURL(u)
+	return nil
+}
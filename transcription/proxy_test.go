@@ -0,0 +1,28 @@
+package transcription
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetProxy(t *testing.T) {
+	assert := assert.New(t)
+
+	defer SetProxy("")
+	assert.NoError(SetProxy("http://proxy.example.com:3128"))
+	expected, _ := url.Parse("http://proxy.example.com:3128")
+
+	request, _ := http.NewRequest("GET", "https://stream.watsonplatform.net/speech-to-text/api", nil)
+	proxyURL, err := IBMOptions{}.dialer().Proxy(request)
+	assert.NoError(err)
+	assert.Equal(expected, proxyURL)
+
+	proxyURL, err = httpClient.Transport.(*http.Transport).Proxy(request)
+	assert.NoError(err)
+	assert.Equal(expected, proxyURL)
+
+	assert.Error(SetProxy("proxy.example.com"))
+}
@@ -0,0 +1,112 @@
+package transcription
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// sentencePause is the shortest pause, in seconds, at which AddPunctuation
+// ends a sentence.
+const sentencePause = 1.0
+
+// AddPunctuation adds basic punctuation to the best transcripts of res, for
+// models without smart formatting. A sentence ends with a period at every
+// pause of sentencePause seconds and at the end of the audio, and the word
+// which starts a sentence is capitalized. Words which already end with
+// punctuation are kept as they are.
+func AddPunctuation(res *IBMResult) {
+	// words refers to the timestamps of every word in order, so that pauses
+	// between results are found
+	var words []*ibmWordTimestamp
+	for i := range res.Results {
+		if len(res.Results[i].Alternatives) == 0 {
+			continue
+		}
+		timestamps := res.Results[i].Alternatives[0].Timestamps
+		for k := range timestamps {
+			words = append(words, &timestamps[k])
+		}
+	}
+
+	for k, word := range words {
+		if k == 0 || word[1].(float64)-words[k-1][2].(float64) >= sentencePause {
+			word[0] = capitalize(word[0].(string))
+		}
+		if k == len(words)-1 || words[k+1][1].(float64)-word[2].(float64) >= sentencePause {
+			word[0] = endSentence(word[0].(string))
+		}
+	}
+
+	for i := range res.Results {
+		if len(res.Results[i].Alternatives) == 0 {
+			continue
+		}
+		alternative := &res.Results[i].Alternatives[0]
+		if len(alternative.Timestamps) == 0 {
+			continue
+		}
+		text := make([]string, len(alternative.Timestamps))
+		for k, ibmTimestamp := range alternative.Timestamps {
+			text[k] = ibmTimestamp[0].(string)
+			if k < len(alternative.WordConfidence) {
+				alternative.WordConfidence[k][0] = text[k]
+			}
+		}
+		// IBM's transcripts end with a space
+		alternative.Transcript = strings.Join(text, " ") + " "
+	}
+}
+
+// endSentence returns word followed by a period, unless it already ends with
+// punctuation.
+func endSentence(word string) string {
+	last, _ := utf8.DecodeLastRuneInString(word)
+	if word == "" || unicode.IsPunct(last) {
+		return word
+	}
+	return word + "."
+}
+
+const (
+	// closingPunctuation belongs to the word before it, without a space.
+	closingPunctuation = ".,;:!?%)]}"
+	// openingBrackets belong to the word after them, without a space.
+	openingBrackets = "([{"
+)
+
+var (
+	// spaceBeforePunctuation matches the spaces before closing punctuation.
+	spaceBeforePunctuation = regexp.MustCompile(`\s+([` + regexp.QuoteMeta(closingPunctuation) + `])`)
+	// spaceAfterBracket matches the spaces after an opening bracket.
+	spaceAfterBracket = regexp.MustCompile(`([` + regexp.QuoteMeta(openingBrackets) + `])\s+`)
+	// missingSpace matches punctuation directly followed by a letter. Periods
+	// and colons are left out so as not to split abbreviations, decimals,
+	// times and urls.
+	missingSpace = regexp.MustCompile(`([,;!?])(\pL)`)
+)
+
+// NormalizeText returns s with every run of whitespace collapsed into a single
+// space and no leading or trailing space. Spaces before punctuation such as
+// periods and commas and after opening brackets are removed, and a space is
+// added after a comma, semicolon, exclamation or question mark followed by a
+// letter. The text of every export of this package is normalized, such as
+// GetTranscription, ToTranscript, the subtitles and the summaries.
+func NormalizeText(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	s = spaceBeforePunctuation.ReplaceAllString(s, "$1")
+	s = spaceAfterBracket.ReplaceAllString(s, "$1")
+	return missingSpace.ReplaceAllString(s, "$1 $2")
+}
+
+// textSeparator returns the space which joins the normalized texts before and
+// after like NormalizeText would. It is empty if before is empty, if after
+// starts with punctuation which belongs to the word before it or if before
+// ends with an opening bracket.
+func textSeparator(before, after string) string {
+	if before == "" || strings.ContainsAny(after[:1], closingPunctuation) || strings.ContainsAny(before[len(before)-1:], openingBrackets) {
+		return ""
+	}
+	return " "
+}
@@ -0,0 +1,107 @@
+package transcription
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddPunctuationAtLongPause(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{
+			{"hello", 0.0, 0.5},
+			{"there", 0.5, 1.0},
+			{"how", 2.5, 3.0},
+		},
+		[]ibmWordTimestamp{
+			{"are", 3.1, 3.4},
+			{"you", 3.4, 3.8},
+		},
+	)
+	res.Results[0].Alternatives[0].WordConfidence = []ibmWordConfidence{
+		{"hello", 0.9}, {"there", 0.9}, {"how", 0.8},
+	}
+
+	AddPunctuation(res)
+
+	assert.Equal("Hello there. How ", res.Results[0].Alternatives[0].Transcript)
+	assert.Equal("are you. ", res.Results[1].Alternatives[0].Transcript)
+	assert.Equal("How", res.Results[0].Alternatives[0].WordConfidence[2][0])
+	assert.Equal("Hello there. How are you.", res.ToTranscript().Text())
+}
+
+func TestAddPunctuationKeepsExistingPunctuation(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult([]ibmWordTimestamp{
+		{"really?", 0.0, 0.5},
+		{"yes", 2.0, 2.5},
+	})
+
+	AddPunctuation(res)
+
+	assert.Equal("Really? Yes. ", res.Results[0].Alternatives[0].Transcript)
+}
+
+func TestNormalizeText(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		text       string
+		normalized string
+	}{
+		{"hello  there", "hello there"},
+		{"hello \t\n there", "hello there"},
+		{"  hello there ", "hello there"},
+		{"hello there .", "hello there."},
+		{"well , really ?  yes !", "well, really? yes!"},
+		{"wait,what;now", "wait, what; now"},
+		{"it is ( mostly ) fine", "it is (mostly) fine"},
+		{"at 3.5 or 10:30 it is 100 %", "at 3.5 or 10:30 it is 100%"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		assert.Equal(test.normalized, NormalizeText(test.text), "normalizing %q", test.text)
+	}
+}
+
+func TestTranscriptTextIsNormalized(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult([]ibmWordTimestamp{
+		{"hello", 0.0, 0.5},
+		{",", 0.5, 0.5},
+		{"there", 0.5, 1.0},
+		{".", 1.0, 1.0},
+	})
+
+	assert.Equal("hello, there.", res.ToTranscript().Text())
+	assert.Equal([]string{"Hello, there."}, res.ToParagraphs())
+	assert.Contains(res.ToSRT(), "\nhello, there.\n")
+}
+
+func TestExportsAreNormalized(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{{"well", 0.0, 0.5}, {",", 0.5, 0.5}, {"hello", 1.0, 1.5}},
+		[]ibmWordTimestamp{{"there", 2.0, 2.5}, {".", 2.5, 2.5}},
+	)
+	res.Results[0].Alternatives[0].Transcript = " well ,  hello "
+	res.Results[1].Alternatives[0].Transcript = "there . "
+
+	normalized := "well, hello there."
+	assert.Equal(normalized, GetTranscription([]*IBMResult{res}).Transcript)
+	text, err := ioutil.ReadAll(res.TextReader())
+	assert.NoError(err)
+	assert.Equal(normalized, string(text))
+	assert.Equal(normalized, res.TailSeconds(10))
+	assert.Equal(normalized, res.BlocksByDuration(10)[0].Text)
+	assert.Equal(normalized, res.WordWindows(10)[0].Text)
+	assert.Equal(normalized, res.HighConfidenceText(0))
+	assert.Equal("well, hello. there.", extractiveSummary(res, SummaryConfig{}))
+	assert.Equal(normalized, VoteWords([]*Transcript{res.ToTranscript()}, 0).Text())
+}
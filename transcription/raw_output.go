@@ -0,0 +1,113 @@
+package transcription
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// messageReader is the part of a websocket.Conn used to read raw messages.
+type messageReader interface {
+	ReadMessage() (messageType int, p []byte, err error)
+}
+
+// rawRecorder is a jsonReader which writes every message it reads to raw as a
+// line of JSON before decoding it.
+type rawRecorder struct {
+	ws  messageReader
+	raw io.Writer
+}
+
+func (r *rawRecorder) ReadJSON(v interface{}) error {
+	_, message, err := r.ws.ReadMessage()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	// IBM indents its messages, so they are compacted to fit on one line
+	var line bytes.Buffer
+	if err := json.Compact(&line, message); err != nil {
+		return errors.Trace(err)
+	}
+	line.WriteByte('\n')
+	if _, err := r.raw.Write(line.Bytes()); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(json.Unmarshal(message, v))
+}
+
+// rawOutputPath returns the path of the file which receives the messages of
+// IBM. .gz is appended to RawOutputPath if GzipRawOutput is set.
+func (opts IBMOptions) rawOutputPath() string {
+	if opts.GzipRawOutput && !strings.HasSuffix(opts.RawOutputPath, ".gz") {
+		return opts.RawOutputPath + ".gz"
+	}
+	return opts.RawOutputPath
+}
+
+// gzipFile is a file written through a gzip writer.
+type gzipFile struct {
+	*gzip.Writer
+	file *os.File
+}
+
+func (f *gzipFile) Close() error {
+	if err := f.Writer.Close(); err != nil {
+		f.file.Close()
+		return errors.Trace(err)
+	}
+	return errors.Trace(f.file.Close())
+}
+
+// createRawOutput creates the file which receives the messages of IBM.
+func createRawOutput(opts IBMOptions) (io.WriteCloser, error) {
+	file, err := os.Create(opts.rawOutputPath())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if !opts.GzipRawOutput {
+		return file, nil
+	}
+	return &gzipFile{Writer: gzip.NewWriter(file), file: file}, nil
+}
+
+// ReadRawOutput returns the result of the messages of IBM saved at path by
+// IBMOptions.RawOutputPath, as if they were received again. The file may be
+// compressed with gzip. Results which IBM sent again after a reconnect
+// replace the earlier results at the same index.
+func ReadRawOutput(path string) (*IBMResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer file.Close()
+
+	buffered := bufio.NewReader(file)
+	var r io.Reader = buffered
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		defer gzipReader.Close()
+		r = gzipReader
+	}
+
+	results := newResultAccumulator()
+	decoder := json.NewDecoder(r)
+	for {
+		msg := new(ibmMessage)
+		if err := decoder.Decode(msg); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Annotatef(err, "could not read the messages in %s", path)
+		}
+		results.add(&msg.IBMResult)
+	}
+	return results.result(), nil
+}
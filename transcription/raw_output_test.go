@@ -0,0 +1,58 @@
+package transcription
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRawOutputRoundTripsThroughGzip(t *testing.T) {
+	assert := assert.New(t)
+
+	server, _ := newDroppingIBMServer(t, 0)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "raw")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "transcript.ndjson")
+
+	creds := IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	opts := IBMOptions{RawOutputPath: path, GzipRawOutput: true}
+	res, err := TranscribeWithIBMContext(context.Background(), "test.flac", nil, creds, opts)
+	assert.NoError(err)
+
+	// the file is compressed
+	file, err := os.Open(path + ".gz")
+	if !assert.NoError(err) {
+		return
+	}
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	assert.NoError(err)
+	content, err := ioutil.ReadAll(gzipReader)
+	assert.NoError(err)
+	assert.Contains(string(content), `"state":"listening"`)
+
+	replayed, err := ReadRawOutput(path + ".gz")
+	assert.NoError(err)
+	assert.Equal(transcripts(res), transcripts(replayed))
+	assert.Equal([]string{"one ", "two "}, transcripts(replayed))
+}
+
+func TestReadRawOutputReadsUncompressedFile(t *testing.T) {
+	assert := assert.New(t)
+
+	raw := `{"state":"listening"}` + "\n" + mockResult(0, ibmWordTimestamp{"hello", 0, 0.5}) + "\n"
+	path := writeTempFile(t, []byte(raw))
+	defer os.Remove(path)
+
+	res, err := ReadRawOutput(path)
+	assert.NoError(err)
+	assert.Equal([]string{"hello "}, transcripts(res))
+}
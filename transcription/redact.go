@@ -0,0 +1,60 @@
+package transcription
+
+import (
+	"regexp"
+	"strings"
+)
+
+// redacted replaces redacted text in transcripts.
+const redacted = "[REDACTED]"
+
+// RedactTranscript replaces text matching any of patterns with [REDACTED] in
+// the transcripts of res. Matched words are replaced one by one, so their
+// timestamps and confidences are kept and captions still align.
+func RedactTranscript(res *IBMResult, patterns []*regexp.Regexp) {
+	for i := range res.Results {
+		alternatives := res.Results[i].Alternatives
+		for j := range alternatives {
+			redactAlternative(&alternatives[j], patterns)
+		}
+	}
+}
+
+func redactAlternative(alternative *ibmAlternativesField, patterns []*regexp.Regexp) {
+	for _, pattern := range patterns {
+		alternative.Transcript = pattern.ReplaceAllString(alternative.Transcript, redacted)
+	}
+
+	// join the words so that patterns spanning several words are matched, and
+	// remember where each word starts
+	words := make([]string, len(alternative.Timestamps))
+	starts := make([]int, len(alternative.Timestamps))
+	offset := 0
+	for k, ibmTimestamp := range alternative.Timestamps {
+		words[k] = ibmTimestamp[0].(string)
+		starts[k] = offset
+		offset += len(words[k]) + 1
+	}
+	text := strings.Join(words, " ")
+
+	redact := make([]bool, len(words))
+	for _, pattern := range patterns {
+		for _, match := range pattern.FindAllStringIndex(text, -1) {
+			for k := range words {
+				if starts[k] < match[1] && starts[k]+len(words[k]) > match[0] {
+					redact[k] = true
+				}
+			}
+		}
+	}
+
+	for k := range words {
+		if !redact[k] {
+			continue
+		}
+		alternative.Timestamps[k][0] = redacted
+		if k < len(alternative.WordConfidence) {
+			alternative.WordConfidence[k][0] = redacted
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package transcription
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactTranscriptPhoneNumber(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{
+			{"call", 0.0, 0.5},
+			{"555", 0.5, 1.0},
+			{"123", 1.0, 1.5},
+			{"4567", 1.5, 2.0},
+			{"today", 2.0, 2.5},
+		},
+	)
+	res.Results[0].Alternatives[0].WordConfidence = []ibmWordConfidence{
+		{"call", 0.9}, {"555", 0.8}, {"123", 0.8}, {"4567", 0.8}, {"today", 0.9},
+	}
+
+	RedactTranscript(res, []*regexp.Regexp{regexp.MustCompile(`\d{3} \d{3} \d{4}`)})
+
+	alternative := res.Results[0].Alternatives[0]
+	assert.Equal("call [REDACTED] today ", alternative.Transcript)
+	assert.Equal([]ibmWordTimestamp{
+		{"call", 0.0, 0.5},
+		{"[REDACTED]", 0.5, 1.0},
+		{"[REDACTED]", 1.0, 1.5},
+		{"[REDACTED]", 1.5, 2.0},
+		{"today", 2.0, 2.5},
+	}, alternative.Timestamps)
+	assert.Equal("[REDACTED]", alternative.WordConfidence[1][0])
+	assert.Equal(0.8, alternative.WordConfidence[1][1])
+}
@@ -0,0 +1,41 @@
+package transcription
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// BatchReport returns a plain text report of a batch of transcriptions, such
+// as the one emailed after a nightly batch. results maps the name of each file
+// to its result. There is a section with the statistics of each file, in
+// order of name, followed by the totals of the batch. The average confidence
+// of the batch is weighted by the words of each file.
+func BatchReport(results map[string]*IBMResult) string {
+	names := sortedResultNames(results)
+
+	var report bytes.Buffer
+	total := Stats{}
+	confidence := 0.0
+	for _, name := range names {
+		stats := TranscriptStats(results[name])
+		writeReportSection(&report, name, stats)
+		report.WriteString("\n")
+
+		total.Words += stats.Words
+		total.Duration += stats.Duration
+		confidence += stats.AverageConfidence * float64(stats.Words)
+	}
+	if total.Words > 0 {
+		total.AverageConfidence = confidence / float64(total.Words)
+	}
+	writeReportSection(&report, fmt.Sprintf("Total (%d files)", len(names)), total)
+	return report.String()
+}
+
+// writeReportSection writes the statistics of a section of a batch report.
+func writeReportSection(report *bytes.Buffer, title string, stats Stats) {
+	fmt.Fprintf(report, "%s\n", title)
+	fmt.Fprintf(report, "  Words: %d\n", stats.Words)
+	fmt.Fprintf(report, "  Duration: %.1f seconds\n", stats.Duration)
+	fmt.Fprintf(report, "  Average confidence: %.2f\n", stats.AverageConfidence)
+}
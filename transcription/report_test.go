@@ -0,0 +1,47 @@
+package transcription
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchReportTotals(t *testing.T) {
+	assert := assert.New(t)
+
+	first := newTimestampedResult([]ibmWordTimestamp{
+		{"hello", 0.0, 1.0},
+		{"world", 1.0, 2.0},
+		{"again", 2.0, 4.0},
+	})
+	first.Results[0].Alternatives[0].WordConfidence = []ibmWordConfidence{
+		{"hello", 0.9}, {"world", 0.9}, {"again", 0.9},
+	}
+	second := newTimestampedResult([]ibmWordTimestamp{
+		{"goodbye", 0.0, 6.0},
+	})
+	second.Results[0].Alternatives[0].WordConfidence = []ibmWordConfidence{
+		{"goodbye", 0.5},
+	}
+
+	report := BatchReport(map[string]*IBMResult{
+		"second.flac": second,
+		"first.flac":  first,
+	})
+
+	assert.Equal(`first.flac
+  Words: 3
+  Duration: 4.0 seconds
+  Average confidence: 0.90
+
+second.flac
+  Words: 1
+  Duration: 6.0 seconds
+  Average confidence: 0.50
+
+Total (2 files)
+  Words: 4
+  Duration: 10.0 seconds
+  Average confidence: 0.80
+`, report)
+}
@@ -0,0 +1,292 @@
+package transcription
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const defaultS3PartSize = 8 * 1024 * 1024
+
+// S3Config contains the credentials and settings used to upload to S3.
+type S3Config struct {
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Endpoint is the url of the S3 service. Defaults to
+	// https://s3.<Region>.amazonaws.com. Set it to use an S3-compatible service.
+	Endpoint string
+	// PartSize is the size of each part of a multipart upload. Files of at
+	// most PartSize bytes are uploaded in a single request. Defaults to 8MB;
+	// S3 requires at least 5MB for every part but the last.
+	PartSize int64
+}
+
+func (cfg S3Config) endpoint() string {
+	if cfg.Endpoint != "" {
+		return strings.TrimRight(cfg.Endpoint, "/")
+	}
+	return "https://s3." + cfg.Region + ".amazonaws.com"
+}
+
+func (cfg S3Config) partSize() int64 {
+	if cfg.PartSize > 0 {
+		return cfg.PartSize
+	}
+	return defaultS3PartSize
+}
+
+// UploadFileToS3 uploads the file at localPath to key in the given S3 bucket and
+// returns the url of the object. Large files are uploaded in parts. It returns
+// an *UploadError if the upload fails.
+func UploadFileToS3(ctx context.Context, localPath, bucket, key string, cfg S3Config) (string, error) {
+	objectURL, err := uploadFileToS3(ctx, localPath, bucket, key, cfg)
+	if err != nil {
+		return "", &UploadError{Path: localPath, Err: err}
+	}
+	return objectURL, nil
+}
+
+func uploadFileToS3(ctx context.Context, localPath, bucket, key string, cfg S3Config) (string, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	objectURL := cfg.endpoint() + "/" + s3Escape(bucket, false) + "/" + s3Escape(key, false)
+	if stat.Size() <= cfg.partSize() {
+		body, err := ioutil.ReadAll(file)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		if _, err := doS3Request(ctx, cfg, "PUT", objectURL, nil, body); err != nil {
+			return "", errors.Trace(err)
+		}
+		return objectURL, nil
+	}
+
+	if err := multipartUploadToS3(ctx, file, objectURL, cfg); err != nil {
+		return "", errors.Trace(err)
+	}
+	return objectURL, nil
+}
+
+type s3CompletedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// multipartUploadToS3 uploads r to objectURL in parts of cfg.PartSize. The
+// upload is aborted if any part fails.
+func multipartUploadToS3(ctx context.Context, r io.Reader, objectURL string, cfg S3Config) error {
+	response, err := doS3Request(ctx, cfg, "POST", objectURL, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(response, &initiated); err != nil {
+		return errors.Trace(err)
+	}
+
+	parts, err := uploadS3Parts(ctx, r, objectURL, initiated.UploadID, cfg)
+	if err != nil {
+		// abort the upload so that S3 does not keep the parts
+		doS3Request(ctx, cfg, "DELETE", objectURL, url.Values{"uploadId": {initiated.UploadID}}, nil)
+		return errors.Trace(err)
+	}
+
+	complete, err := xml.Marshal(struct {
+		XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+		Parts   []s3CompletedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = doS3Request(ctx, cfg, "POST", objectURL, url.Values{"uploadId": {initiated.UploadID}}, complete)
+	return errors.Trace(err)
+}
+
+// uploadS3Parts uploads each part of r and returns the completed parts.
+func uploadS3Parts(ctx context.Context, r io.Reader, objectURL, uploadID string, cfg S3Config) ([]s3CompletedPart, error) {
+	parts := []s3CompletedPart{}
+	buffer := make([]byte, cfg.partSize())
+	for partNumber := 1; ; partNumber++ {
+		n, err := io.ReadFull(r, buffer)
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return nil, errors.Trace(err)
+		}
+
+		query := url.Values{
+			"partNumber": {strconv.Itoa(partNumber)},
+			"uploadId":   {uploadID},
+		}
+		request, err := newS3Request(ctx, cfg, "PUT", objectURL, query, buffer[:n])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		response, err := httpClient.Do(request)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return nil, errors.Errorf("uploading part %d to S3 failed with status %s", partNumber, response.Status)
+		}
+		parts = append(parts, s3CompletedPart{
+			PartNumber: partNumber,
+			ETag:       response.Header.Get("ETag"),
+		})
+
+		if n < len(buffer) {
+			return parts, nil
+		}
+	}
+}
+
+// doS3Request sends a signed request to S3 and returns the response body.
+func doS3Request(ctx context.Context, cfg S3Config, method, objectURL string, query url.Values, body []byte) ([]byte, error) {
+	request, err := newS3Request(ctx, cfg, method, objectURL, query, body)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer response.Body.Close()
+
+	contents, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, errors.Errorf("S3 %s request failed with status %s: %s", method, response.Status, contents)
+	}
+	return contents, nil
+}
+
+// newS3Request returns a request signed with AWS Signature Version 4.
+func newS3Request(ctx context.Context, cfg S3Config, method, objectURL string, query url.Values, body []byte) (*http.Request, error) {
+	u, err := url.Parse(objectURL)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	u.RawQuery = s3CanonicalQuery(query)
+
+	request, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("User-Agent", UserAgent)
+
+	now := time.Now().UTC()
+	payloadHash := sha256Hex(body)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	request.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	request.Header.Set("Authorization", s3Authorization(cfg, request, u, payloadHash, now))
+	return request, nil
+}
+
+// s3Authorization returns the Authorization header of a request.
+func s3Authorization(cfg S3Config, request *http.Request, u *url.URL, payloadHash string, now time.Time) string {
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		u.EscapedPath(),
+		u.RawQuery,
+		"host:" + u.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + request.Header.Get("X-Amz-Date") + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	date := now.Format("20060102")
+	scope := date + "/" + cfg.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		request.Header.Get("X-Amz-Date"),
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	return fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		cfg.AccessKeyID, scope, signedHeaders, signature)
+}
+
+// s3CanonicalQuery encodes query sorted by key, as required for signing.
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := []string{}
+	for _, k := range keys {
+		for _, v := range query[k] {
+			pairs = append(pairs, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape percent-encodes all but the unreserved characters of s. Slashes
+// are kept unless escapeSlash is set.
+func s3Escape(s string, escapeSlash bool) string {
+	var buffer bytes.Buffer
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~', b == '/' && !escapeSlash:
+			buffer.WriteByte(b)
+		default:
+			fmt.Fprintf(&buffer, "%%%02X", b)
+		}
+	}
+	return buffer.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,113 @@
+package transcription
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockS3 is a minimal S3 server which supports multipart uploads.
+type mockS3 struct {
+	sync.Mutex
+	parts     map[string][]byte
+	objects   map[string][]byte
+	completed bool
+}
+
+func (s *mockS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	body, _ := ioutil.ReadAll(r.Body)
+	switch {
+	case r.Method == "POST" && r.URL.RawQuery == "uploads=":
+		fmt.Fprint(w, "<InitiateMultipartUploadResult><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>")
+	case r.Method == "PUT" && query.Get("uploadId") == "upload-1":
+		s.parts[query.Get("partNumber")] = body
+		w.Header().Set("ETag", `"etag-`+query.Get("partNumber")+`"`)
+	case r.Method == "POST" && query.Get("uploadId") == "upload-1":
+		var object []byte
+		for i := 1; i <= len(s.parts); i++ {
+			if !bytes.Contains(body, []byte(fmt.Sprintf("<PartNumber>%d</PartNumber><ETag>&#34;etag-%d&#34;</ETag>", i, i))) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			object = append(object, s.parts[fmt.Sprint(i)]...)
+		}
+		s.objects[r.URL.Path] = object
+		s.completed = true
+	case r.Method == "PUT":
+		s.objects[r.URL.Path] = body
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func writeTempFile(t *testing.T, contents []byte) string {
+	file, err := ioutil.TempFile("", "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	file.Write(contents)
+	return file.Name()
+}
+
+func TestUploadFileToS3Multipart(t *testing.T) {
+	assert := assert.New(t)
+
+	s3 := &mockS3{parts: map[string][]byte{}, objects: map[string][]byte{}}
+	server := httptest.NewServer(s3)
+	defer server.Close()
+
+	contents := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	filePath := writeTempFile(t, contents)
+	defer os.Remove(filePath)
+
+	cfg := S3Config{
+		Region:          "us-east-1",
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+		Endpoint:        server.URL,
+		PartSize:        10,
+	}
+	objectURL, err := UploadFileToS3(context.Background(), filePath, "bucket", "audio/file.flac", cfg)
+	assert.NoError(err)
+	assert.Equal(server.URL+"/bucket/audio/file.flac", objectURL)
+
+	assert.True(s3.completed)
+	assert.Len(s3.parts, 4)
+	assert.Equal(contents, s3.objects["/bucket/audio/file.flac"])
+}
+
+func TestUploadFileToS3SinglePart(t *testing.T) {
+	assert := assert.New(t)
+
+	s3 := &mockS3{parts: map[string][]byte{}, objects: map[string][]byte{}}
+	server := httptest.NewServer(s3)
+	defer server.Close()
+
+	filePath := writeTempFile(t, []byte("small"))
+	defer os.Remove(filePath)
+
+	cfg := S3Config{Region: "us-east-1", AccessKeyID: "key", SecretAccessKey: "secret", Endpoint: server.URL}
+	_, err := UploadFileToS3(context.Background(), filePath, "bucket", "small.flac", cfg)
+	assert.NoError(err)
+	assert.False(s3.completed)
+	assert.Equal([]byte("small"), s3.objects["/bucket/small.flac"])
+}
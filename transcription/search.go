@@ -0,0 +1,37 @@
+package transcription
+
+import (
+	"fmt"
+)
+
+// SearchDoc is a sentence of a recording for indexing by a search engine,
+// such as Elasticsearch or Bleve.
+type SearchDoc struct {
+	// ID is unique among the documents of all recordings.
+	ID string `json:"id"`
+	// Recording is the id of the recording the sentence is from.
+	Recording string  `json:"recording"`
+	Text      string  `json:"text"`
+	Start     float64 `json:"start"`
+	End       float64 `json:"end"`
+}
+
+// ToSearchDocuments returns a document for every final segment of r, which
+// IBM ends at the end of a sentence or a pause. id identifies the recording,
+// and the ID of each document is id followed by the index of the segment.
+func (r *IBMResult) ToSearchDocuments(id string) []SearchDoc {
+	docs := []SearchDoc{}
+	for i, segment := range r.ToTranscript().Segments {
+		if segment.Text == "" {
+			continue
+		}
+		docs = append(docs, SearchDoc{
+			ID:        fmt.Sprintf("%s-%d", id, i),
+			Recording: id,
+			Text:      segment.Text,
+			Start:     segment.Start,
+			End:       segment.End,
+		})
+	}
+	return docs
+}
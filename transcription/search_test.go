@@ -0,0 +1,22 @@
+package transcription
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToSearchDocuments(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{{"hello", 0.5, 1.0}, {"there", 1.0, 1.4}},
+		[]ibmWordTimestamp{},
+		[]ibmWordTimestamp{{"general", 2.0, 2.5}, {"kenobi", 2.5, 3.0}},
+	)
+
+	assert.Equal([]SearchDoc{
+		{ID: "lecture-0", Recording: "lecture", Text: "hello there", Start: 0.5, End: 1.4},
+		{ID: "lecture-2", Recording: "lecture", Text: "general kenobi", Start: 2.0, End: 3.0},
+	}, res.ToSearchDocuments("lecture"))
+}
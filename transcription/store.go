@@ -0,0 +1,130 @@
+package transcription
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// Store persists transcripts by id.
+type Store interface {
+	// Save stores t under id, replacing any transcript stored under it before.
+	Save(ctx context.Context, id string, t *Transcript) error
+	// Load returns the transcript stored under id. The error satisfies
+	// errors.IsNotFound if there is none.
+	Load(ctx context.Context, id string) (*Transcript, error)
+}
+
+// MemoryStore is a Store which keeps transcripts in memory, such as for tests.
+// The zero value is an empty store.
+type MemoryStore struct {
+	mu          sync.Mutex
+	transcripts map[string][]byte
+}
+
+// Save implements Store. The transcript is copied, so later changes to t are
+// not stored.
+func (s *MemoryStore) Save(ctx context.Context, id string, t *Transcript) error {
+	data, err := t.ToJSON()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.transcripts == nil {
+		s.transcripts = map[string][]byte{}
+	}
+	s.transcripts[id] = data
+	return nil
+}
+
+// Load implements Store.
+func (s *MemoryStore) Load(ctx context.Context, id string) (*Transcript, error) {
+	s.mu.Lock()
+	data, ok := s.transcripts[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, errors.NotFoundf("transcript %s", id)
+	}
+	return decodeTranscript(data)
+}
+
+// SQLStore is a Store which keeps transcripts in a SQLite database. The
+// transcript is stored as JSON next to columns of its metadata, which are
+// indexed so that transcripts can be looked up by file and time.
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore returns a store in db, creating its table if it does not exist.
+// db must be opened with a SQLite driver, such as github.com/mattn/go-sqlite3,
+// which is vendored for the tests and needs cgo and a C compiler.
+func NewSQLStore(ctx context.Context, db *sql.DB) (*SQLStore, error) {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS transcripts (
+			id TEXT PRIMARY KEY,
+			filename TEXT,
+			model TEXT,
+			duration REAL,
+			transcribed_at TIMESTAMP,
+			data BLOB NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS transcripts_filename ON transcripts (filename)`,
+		`CREATE INDEX IF NOT EXISTS transcripts_transcribed_at ON transcripts (transcribed_at)`,
+	}
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return nil, errors.Annotate(err, "could not create the transcripts table")
+		}
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// Save implements Store.
+func (s *SQLStore) Save(ctx context.Context, id string, t *Transcript) error {
+	data, err := t.ToJSON()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	var filename, model, duration, transcribedAt interface{}
+	if t.Metadata != nil {
+		filename = t.Metadata.Filename
+		model = t.Metadata.Model
+		duration = t.Metadata.Duration
+		transcribedAt = t.Metadata.TranscribedAt.UTC().Format(time.RFC3339Nano)
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO transcripts (id, filename, model, duration, transcribed_at, data)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		id, filename, model, duration, transcribedAt, data)
+	if err != nil {
+		return errors.Annotatef(err, "could not save transcript %s", id)
+	}
+	return nil
+}
+
+// Load implements Store.
+func (s *SQLStore) Load(ctx context.Context, id string) (*Transcript, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT data FROM transcripts WHERE id = ?`, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, errors.NotFoundf("transcript %s", id)
+	}
+	if err != nil {
+		return nil, errors.Annotatef(err, "could not load transcript %s", id)
+	}
+	return decodeTranscript(data)
+}
+
+// decodeTranscript returns the transcript of the JSON made by ToJSON.
+func decodeTranscript(data []byte) (*Transcript, error) {
+	t := new(Transcript)
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return t, nil
+}
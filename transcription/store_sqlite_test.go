@@ -0,0 +1,37 @@
+// The SQLite driver is written in C, so these tests only run with cgo. A run
+// with CGO_ENABLED=0 skips them and stays pure Go.
+
+//go:build cgo
+
+package transcription
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLStoreRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(err)
+	defer db.Close()
+	// every connection to :memory: has its own database
+	db.SetMaxOpenConns(1)
+
+	store, err := NewSQLStore(context.Background(), db)
+	assert.NoError(err)
+	testStoreRoundTrip(t, store)
+
+	transcript := &Transcript{Metadata: &TranscriptMetadata{Filename: "test.flac", Model: defaultIBMModel}}
+	assert.NoError(store.Save(context.Background(), "second", transcript))
+	var filename, model string
+	row := db.QueryRow(`SELECT filename, model FROM transcripts WHERE id = ?`, "second")
+	assert.NoError(row.Scan(&filename, &model))
+	assert.Equal("test.flac", filename)
+	assert.Equal(defaultIBMModel, model)
+}
@@ -0,0 +1,47 @@
+package transcription
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// testStoreRoundTrip checks that transcripts saved to store are loaded again.
+func testStoreRoundTrip(t *testing.T, store Store) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	transcript := newTimestampedResult([]ibmWordTimestamp{
+		{"hello", 0.0, 0.5},
+		{"world", 0.5, 1.0},
+	}).ToTranscript()
+	transcript.Metadata = &TranscriptMetadata{
+		Filename:      "test.flac",
+		Duration:      1.5,
+		Model:         defaultIBMModel,
+		TranscribedAt: time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	assert.NoError(store.Save(ctx, "first", transcript))
+	loaded, err := store.Load(ctx, "first")
+	assert.NoError(err)
+	assert.Equal(transcript, loaded)
+
+	// saving under the same id replaces the transcript
+	other := &Transcript{Segments: []TranscriptSegment{{Text: "replaced"}}}
+	assert.NoError(store.Save(ctx, "first", other))
+	loaded, err = store.Load(ctx, "first")
+	assert.NoError(err)
+	assert.Equal("replaced", loaded.Text())
+	assert.Nil(loaded.Metadata)
+
+	_, err = store.Load(ctx, "missing")
+	assert.True(errors.IsNotFound(err))
+}
+
+func TestMemoryStoreRoundTrip(t *testing.T) {
+	testStoreRoundTrip(t, &MemoryStore{})
+}
@@ -0,0 +1,160 @@
+package transcription
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/juju/errors"
+)
+
+// SubtitleFormat is a file format for subtitles.
+type SubtitleFormat string
+
+// The subtitle formats which transcripts can be exported to.
+const (
+	SRT SubtitleFormat = "srt"
+	VTT SubtitleFormat = "vtt"
+)
+
+// CueOptions configures how words are grouped into subtitle cues. A cue ends
+// before the word which would exceed any of the limits. Zero fields use the
+// defaults.
+type CueOptions struct {
+	// MaxWords is the most words in a cue. Defaults to no limit.
+	MaxWords int
+	// MaxDuration is the longest a cue is shown, in seconds. Defaults to 7.
+	MaxDuration int
+	// MaxChars is the most characters, not bytes, in a cue. Defaults to 42,
+	// a common limit of broadcast captions.
+	MaxChars int
+}
+
+func (opts CueOptions) maxDuration() float64 {
+	if opts.MaxDuration > 0 {
+		return float64(opts.MaxDuration)
+	}
+	return 7
+}
+
+func (opts CueOptions) maxChars() int {
+	if opts.MaxChars > 0 {
+		return opts.MaxChars
+	}
+	return 42
+}
+
+// cue is a subtitle shown from start to end.
+type cue struct {
+	start float64
+	end   float64
+	text  string
+	words int
+}
+
+// cues groups the words of t into subtitle cues. Cues are split between words
+// and never inside a multi-byte character.
+func (t *Transcript) cues(opts CueOptions) []cue {
+	cues := []cue{}
+	var current *cue
+	for _, word := range t.Words() {
+		if current != nil {
+			length := utf8.RuneCountInString(current.text) + 1 + utf8.RuneCountInString(word.Text)
+			tooManyWords := opts.MaxWords > 0 && current.words == opts.MaxWords
+			if tooManyWords || length > opts.maxChars() || word.End-current.start > opts.maxDuration() {
+				current.text = NormalizeText(current.text)
+				cues = append(cues, *current)
+				current = nil
+			}
+		}
+		if current == nil {
+			current = &cue{start: word.Start, end: word.End, text: word.Text, words: 1}
+			continue
+		}
+		current.text += " " + word.Text
+		current.end = word.End
+		current.words++
+	}
+	if current != nil {
+		current.text = NormalizeText(current.text)
+		cues = append(cues, *current)
+	}
+	return cues
+}
+
+// ToSRT returns the transcript as SubRip subtitles with the default cue
+// options. The subtitles are UTF-8 encoded, like all exports of this package.
+func (t *Transcript) ToSRT() string {
+	return t.ToSRTWithOptions(CueOptions{})
+}
+
+// ToSRTWithOptions returns the transcript as SubRip subtitles whose cues are
+// grouped according to opts.
+func (t *Transcript) ToSRTWithOptions(opts CueOptions) string {
+	var buffer bytes.Buffer
+	for i, c := range t.cues(opts) {
+		fmt.Fprintf(&buffer, "%d\n%s --> %s\n%s\n\n", i+1, subtitleTime(c.start, ","), subtitleTime(c.end, ","), c.text)
+	}
+	return buffer.String()
+}
+
+// ToVTT returns the transcript as WebVTT subtitles, which are always UTF-8,
+// with the default cue options.
+func (t *Transcript) ToVTT() string {
+	return t.ToVTTWithOptions(CueOptions{})
+}
+
+// ToVTTWithOptions returns the transcript as WebVTT subtitles whose cues are
+// grouped according to opts.
+func (t *Transcript) ToVTTWithOptions(opts CueOptions) string {
+	var buffer bytes.Buffer
+	buffer.WriteString("WEBVTT\n\n")
+	if m := t.Metadata; m != nil {
+		fmt.Fprintf(&buffer, "NOTE\nSource: %s\nDuration: %.3f seconds\nModel: %s\nTranscribed at: %s\n\n",
+			m.Filename, m.Duration, m.Model, m.TranscribedAt.Format(time.RFC3339))
+	}
+	for _, c := range t.cues(opts) {
+		fmt.Fprintf(&buffer, "%s --> %s\n%s\n\n", subtitleTime(c.start, "."), subtitleTime(c.end, "."), c.text)
+	}
+	return buffer.String()
+}
+
+// ToSRT returns the transcript of r as SubRip subtitles.
+func (r *IBMResult) ToSRT() string {
+	return r.ToTranscript().ToSRT()
+}
+
+// ToVTT returns the transcript of r as WebVTT subtitles.
+func (r *IBMResult) ToVTT() string {
+	return r.ToTranscript().ToVTT()
+}
+
+// WriteSubtitlesToFile writes the transcript of res to the file at path as
+// subtitles in the given format. The extension of path must match the format,
+// so that players read the file correctly.
+func WriteSubtitlesToFile(res *IBMResult, path string, format SubtitleFormat) error {
+	var subtitles string
+	switch format {
+	case SRT:
+		subtitles = res.ToSRT()
+	case VTT:
+		subtitles = res.ToVTT()
+	default:
+		return errors.Errorf("unknown subtitle format %q", format)
+	}
+	if ext := filepath.Ext(path); !strings.EqualFold(ext, "."+string(format)) {
+		return errors.Errorf("cannot write %s subtitles to %s, the extension should be .%s", format, path, format)
+	}
+	return errors.Trace(ioutil.WriteFile(path, []byte(subtitles), 0644))
+}
+
+// subtitleTime formats seconds as hh:mm:ss<sep>mmm.
+func subtitleTime(seconds float64, sep string) string {
+	millis := int64(math.Floor(seconds*1000 + 0.5))
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", millis/3600000, millis/60000%60, millis/1000%60, sep, millis%1000)
+}
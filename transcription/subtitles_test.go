@@ -0,0 +1,119 @@
+package transcription
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// parseSRT returns the text of each cue of SubRip subtitles.
+func parseSRT(srt string) []string {
+	texts := []string{}
+	for _, block := range strings.Split(strings.TrimSpace(srt), "\n\n") {
+		lines := strings.SplitN(block, "\n", 3)
+		if len(lines) == 3 {
+			texts = append(texts, lines[2])
+		}
+	}
+	return texts
+}
+
+func TestToSRT(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{
+			{"hello", 0.5, 1.0},
+			{"there", 1.0, 1.4},
+		},
+		[]ibmWordTimestamp{
+			{"hi", 61.25, 62.5},
+		},
+	)
+
+	assert.Equal("1\n00:00:00,500 --> 00:00:01,400\nhello there\n\n"+
+		"2\n00:01:01,250 --> 00:01:02,500\nhi\n\n", res.ToSRT())
+	assert.Equal("WEBVTT\n\n00:00:00.500 --> 00:00:01.400\nhello there\n\n"+
+		"00:01:01.250 --> 00:01:02.500\nhi\n\n", res.ToVTT())
+}
+
+func TestToSRTRoundTripsMultiByteText(t *testing.T) {
+	assert := assert.New(t)
+
+	// each word is 5 characters but 15 bytes, so a limit counted in bytes
+	// would split these words into many more cues, or cut them in half.
+	words := []ibmWordTimestamp{}
+	for i := 0; i < 15; i++ {
+		words = append(words, ibmWordTimestamp{"こんにちは", float64(i) * 0.1, float64(i)*0.1 + 0.1})
+	}
+	words = append(words, ibmWordTimestamp{"世界", 1.5, 1.6})
+	res := newTimestampedResult(words)
+
+	texts := parseSRT(res.ToSRT())
+	assert.Equal(res.ToTranscript().Text(), strings.Join(texts, " "))
+	for _, text := range texts {
+		for _, word := range strings.Split(text, " ") {
+			assert.True(word == "こんにちは" || word == "世界", "word %q was cut", word)
+		}
+	}
+}
+
+func TestWriteSubtitlesToFileChecksExtension(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "subtitles")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	res := newTimestampedResult([]ibmWordTimestamp{{"hello", 0.5, 1.0}})
+
+	path := filepath.Join(dir, "subtitles.vtt")
+	err = WriteSubtitlesToFile(res, path, SRT)
+	assert.Error(err)
+	_, err = os.Stat(path)
+	assert.True(os.IsNotExist(err))
+
+	assert.NoError(WriteSubtitlesToFile(res, path, VTT))
+	contents, err := ioutil.ReadFile(path)
+	assert.NoError(err)
+	assert.Equal(res.ToVTT(), string(contents))
+
+	assert.NoError(WriteSubtitlesToFile(res, filepath.Join(dir, "SUBTITLES.SRT"), SRT))
+	assert.Error(WriteSubtitlesToFile(res, filepath.Join(dir, "subtitles"), SRT))
+}
+
+func TestCueOptionsMaxWords(t *testing.T) {
+	assert := assert.New(t)
+
+	transcript := newTimestampedResult([]ibmWordTimestamp{
+		{"one", 0.0, 0.5}, {"two", 0.5, 1.0}, {"three", 1.0, 1.5}, {"four", 1.5, 2.0}, {"five", 2.0, 2.5},
+	}).ToTranscript()
+
+	assert.Equal([]string{"one two three four five"}, parseSRT(transcript.ToSRT()))
+	assert.Equal([]string{"one two", "three four", "five"}, parseSRT(transcript.ToSRTWithOptions(CueOptions{MaxWords: 2})))
+}
+
+func TestCueOptionsMaxDuration(t *testing.T) {
+	assert := assert.New(t)
+
+	transcript := newTimestampedResult([]ibmWordTimestamp{
+		{"one", 0.0, 1.0}, {"two", 1.0, 2.0}, {"three", 2.0, 3.0}, {"four", 3.0, 4.0},
+	}).ToTranscript()
+
+	assert.Equal([]string{"one two", "three four"}, parseSRT(transcript.ToSRTWithOptions(CueOptions{MaxDuration: 2})))
+	assert.Contains(transcript.ToVTTWithOptions(CueOptions{MaxDuration: 2}), "00:00:02.000 --> 00:00:04.000\nthree four\n")
+}
+
+func TestCueOptionsMaxChars(t *testing.T) {
+	assert := assert.New(t)
+
+	transcript := newTimestampedResult([]ibmWordTimestamp{
+		{"alpha", 0.0, 0.5}, {"beta", 0.5, 1.0}, {"gamma", 1.0, 1.5}, {"delta", 1.5, 2.0},
+	}).ToTranscript()
+
+	// "alpha beta" is 10 characters, adding " gamma" would make 16
+	assert.Equal([]string{"alpha beta", "gamma delta"}, parseSRT(transcript.ToSRTWithOptions(CueOptions{MaxChars: 12})))
+}
@@ -0,0 +1,157 @@
+package transcription
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// SummaryConfig configures how transcripts are summarized.
+type SummaryConfig struct {
+	// Endpoint is the url of an LLM summarization service. The transcript is
+	// POSTed as {"text": ..., "format": ..., "max_sentences": ...} and the
+	// service responds with {"summary": ...}. If Endpoint is empty, an
+	// extractive summary is made instead.
+	Endpoint string
+	// APIKey is sent as a bearer token to Endpoint, if set.
+	APIKey string
+	// Markdown formats the summary as a markdown list instead of plain text.
+	Markdown bool
+	// MaxSentences is the length of the summary. Defaults to 3.
+	MaxSentences int
+}
+
+func (cfg SummaryConfig) maxSentences() int {
+	if cfg.MaxSentences > 0 {
+		return cfg.MaxSentences
+	}
+	return 3
+}
+
+func (cfg SummaryConfig) format() string {
+	if cfg.Markdown {
+		return "markdown"
+	}
+	return "plaintext"
+}
+
+// Summarize returns a short summary of the transcript of res.
+func Summarize(ctx context.Context, res *IBMResult, cfg SummaryConfig) (string, error) {
+	if cfg.Endpoint == "" {
+		return extractiveSummary(res, cfg), nil
+	}
+	summary, err := requestSummary(ctx, GetTranscription([]*IBMResult{res}).Transcript, cfg)
+	return summary, errors.Trace(err)
+}
+
+// requestSummary asks the service at cfg.Endpoint to summarize text.
+func requestSummary(ctx context.Context, text string, cfg SummaryConfig) (string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"text":          text,
+		"format":        cfg.format(),
+		"max_sentences": cfg.maxSentences(),
+	})
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	request, err := http.NewRequest("POST", cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("User-Agent", UserAgent)
+	request.Header.Set("Content-Type", "application/json")
+	if cfg.APIKey != "" {
+		request.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", errors.Errorf("summarization failed with status %s", response.Status)
+	}
+
+	var summary struct {
+		Summary string `json:"summary"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&summary); err != nil {
+		return "", errors.Trace(err)
+	}
+	return summary.Summary, nil
+}
+
+// extractiveSummary summarizes res by picking the final segments with the best
+// score. Segments score higher the more confident IBM is about their words and
+// the earlier they are in the recording.
+func extractiveSummary(res *IBMResult, cfg SummaryConfig) string {
+	type sentence struct {
+		index int
+		text  string
+		score float64
+	}
+
+	sentences := []sentence{}
+	for _, subResult := range res.Results {
+		if !subResult.Final || len(subResult.Alternatives) == 0 {
+			continue
+		}
+		alternative := subResult.Alternatives[0]
+		text := NormalizeText(alternative.Transcript)
+		if text == "" {
+			continue
+		}
+		sentences = append(sentences, sentence{
+			index: len(sentences),
+			text:  text,
+			score: averageWordConfidence(alternative),
+		})
+	}
+	for i := range sentences {
+		sentences[i].score += 0.5 * (1 - float64(i)/float64(len(sentences)))
+	}
+
+	sort.SliceStable(sentences, func(i, j int) bool {
+		return sentences[i].score > sentences[j].score
+	})
+	if len(sentences) > cfg.maxSentences() {
+		sentences = sentences[:cfg.maxSentences()]
+	}
+	sort.Slice(sentences, func(i, j int) bool {
+		return sentences[i].index < sentences[j].index
+	})
+
+	lines := make([]string, len(sentences))
+	for i, s := range sentences {
+		if cfg.Markdown {
+			lines[i] = "- " + s.text
+		} else {
+			lines[i] = endSentence(s.text)
+		}
+	}
+	if cfg.Markdown {
+		return strings.Join(lines, "\n")
+	}
+	return strings.Join(lines, " ")
+}
+
+// averageWordConfidence returns the average confidence of the words of
+// alternative, or its overall confidence if there are no word confidences.
+func averageWordConfidence(alternative ibmAlternativesField) float64 {
+	if len(alternative.WordConfidence) == 0 {
+		return alternative.OverallConfidence
+	}
+	total := 0.0
+	for _, ibmConfidence := range alternative.WordConfidence {
+		total += ibmConfidence[1].(float64)
+	}
+	return total / float64(len(alternative.WordConfidence))
+}
@@ -0,0 +1,70 @@
+package transcription
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newConfidenceResult(segments map[string]float64, order ...string) *IBMResult {
+	res := new(IBMResult)
+	for _, transcript := range order {
+		res.Results = append(res.Results, ibmResultField{
+			Alternatives: []ibmAlternativesField{
+				ibmAlternativesField{
+					Transcript:        transcript + " ",
+					OverallConfidence: segments[transcript],
+				},
+			},
+			Final: true,
+		})
+	}
+	return res
+}
+
+func TestSummarizeExtractive(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newConfidenceResult(map[string]float64{
+		"the intro":      0.6,
+		"mumbled aside":  0.1,
+		"the key point":  0.9,
+		"another mumble": 0.2,
+		"the conclusion": 0.95,
+	}, "the intro", "mumbled aside", "the key point", "another mumble", "the conclusion")
+
+	summary, err := Summarize(context.Background(), res, SummaryConfig{})
+	assert.NoError(err)
+	assert.Equal("the intro. the key point. the conclusion.", summary)
+
+	summary, err = Summarize(context.Background(), res, SummaryConfig{Markdown: true, MaxSentences: 2})
+	assert.NoError(err)
+	assert.Equal("- the intro\n- the key point", summary)
+}
+
+func TestSummarizeWithEndpoint(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&request)
+		assert.Equal("hello world", request["text"])
+		assert.Equal("markdown", request["format"])
+		assert.Equal("Bearer key", r.Header.Get("Authorization"))
+		json.NewEncoder(w).Encode(map[string]string{"summary": "- a greeting"})
+	}))
+	defer server.Close()
+
+	res := newConfidenceResult(map[string]float64{"hello world": 0.9}, "hello world")
+	summary, err := Summarize(context.Background(), res, SummaryConfig{
+		Endpoint: server.URL,
+		APIKey:   "key",
+		Markdown: true,
+	})
+	assert.NoError(err)
+	assert.Equal("- a greeting", summary)
+}
@@ -0,0 +1,54 @@
+package transcription
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hack4impact/transcribe4all/transcription/ibmtest"
+)
+
+func TestInsecureSkipVerifyAcceptsSelfSignedIBMServer(t *testing.T) {
+	assert := assert.New(t)
+
+	server := ibmtest.NewTLSMockIBMServer(t, []ibmtest.MockFrame{
+		{JSON: `{"state": "listening"}`},
+		{JSON: mockResult(0, ibmWordTimestamp{"hello", 0.0, 1.0})},
+		{JSON: `{"state": "listening"}`},
+	})
+	defer server.Close()
+
+	creds := IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	_, err := TranscribeWithIBMContext(context.Background(), "test.flac", nil, creds, IBMOptions{})
+	assert.Error(err)
+
+	res, err := TranscribeWithIBMContext(context.Background(), "test.flac", nil, creds, IBMOptions{InsecureSkipVerify: true})
+	assert.NoError(err)
+	assert.Equal([]string{"hello "}, transcripts(res))
+}
+
+func TestInsecureSkipVerifyAcceptsSelfSignedDownloadServer(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("audio"))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	dest := filepath.Join(dir, "audio.flac")
+
+	assert.Error(DownloadFileWithOptions(server.URL, dest, DownloadOptions{}))
+
+	assert.NoError(DownloadFileWithOptions(server.URL, dest, DownloadOptions{InsecureSkipVerify: true}))
+	contents, _ := ioutil.ReadFile(dest)
+	assert.Equal("audio", string(contents))
+}
@@ -0,0 +1,286 @@
+package transcription
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/juju/errors"
+)
+
+// Transcript is a transcript which does not depend on the service which made
+// it, so that exporters and other tools work with any service.
+type Transcript struct {
+	// Metadata describes the source of the transcript, if it is set. It is
+	// included in JSON and WebVTT exports. SubRip has no room for it.
+	Metadata *TranscriptMetadata `json:"metadata,omitempty"`
+	Segments []TranscriptSegment `json:"segments"`
+}
+
+// TranscriptMetadata describes the audio a transcript was made from.
+type TranscriptMetadata struct {
+	Filename string `json:"filename"`
+	// Duration is the length of the audio in seconds, or 0 if it is unknown.
+	Duration      float64   `json:"duration"`
+	Model         string    `json:"model"`
+	TranscribedAt time.Time `json:"transcribed_at"`
+}
+
+// NewTranscriptMetadata returns the metadata of a transcript of the file at
+// filePath made now with model.
+func NewTranscriptMetadata(filePath string, model string) *TranscriptMetadata {
+	metadata := &TranscriptMetadata{
+		Filename:      filepath.Base(filePath),
+		Model:         model,
+		TranscribedAt: time.Now().UTC(),
+	}
+	if info, err := GetAudioInfo(filePath); err == nil {
+		metadata.Duration = info.Duration.Seconds()
+	}
+	return metadata
+}
+
+// ToJSON returns the transcript as JSON.
+func (t *Transcript) ToJSON() ([]byte, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return data, nil
+}
+
+// TranscriptSegment is a continuous piece of a transcript, such as a phrase.
+type TranscriptSegment struct {
+	Text       string  `json:"text"`
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Confidence float64 `json:"confidence"`
+	Words      []Word  `json:"words"`
+}
+
+// Word is a recognized word. Speaker is -1 if the speaker is unknown.
+type Word struct {
+	Text       string  `json:"text"`
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Confidence float64 `json:"confidence"`
+	Speaker    int     `json:"speaker"`
+}
+
+// Text returns the text of every segment of the transcript.
+func (t *Transcript) Text() string {
+	texts := make([]string, len(t.Segments))
+	for i, segment := range t.Segments {
+		texts[i] = segment.Text
+	}
+	return NormalizeText(strings.Join(texts, " "))
+}
+
+// Words returns every word of the transcript, in order.
+func (t *Transcript) Words() []Word {
+	words := []Word{}
+	for _, segment := range t.Segments {
+		words = append(words, segment.Words...)
+	}
+	return words
+}
+
+// ToWordNDJSON returns the words of the final segments of r as newline
+// delimited JSON, with one {"word", "start", "end", "confidence"} object per
+// line.
+func (r *IBMResult) ToWordNDJSON() string {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	for _, word := range r.ToTranscript().Words() {
+		// Encode only fails for values which cannot be represented in JSON
+		encoder.Encode(struct {
+			Word       string  `json:"word"`
+			Start      float64 `json:"start"`
+			End        float64 `json:"end"`
+			Confidence float64 `json:"confidence"`
+		}{word.Text, word.Start, word.End, word.Confidence})
+	}
+	return buffer.String()
+}
+
+// ToTranscript converts the best hypothesis of every final segment of r into a
+// Transcript. Words are attributed to speakers using r's speaker labels.
+func (r *IBMResult) ToTranscript() *Transcript {
+	final := &IBMResult{Results: FinalResults(r), SpeakerLabels: r.SpeakerLabels}
+	speakers := WordsWithSpeakers(final)
+	transcript := &Transcript{Segments: []TranscriptSegment{}}
+
+	wordIndex := 0
+	for _, subResult := range final.Results {
+		if len(subResult.Alternatives) == 0 {
+			continue
+		}
+		alternative := subResult.Alternatives[0]
+		segment := TranscriptSegment{
+			Text:       NormalizeText(alternative.Transcript),
+			Confidence: alternative.OverallConfidence,
+			Words:      make([]Word, len(alternative.Timestamps)),
+		}
+		for i, ibmTimestamp := range alternative.Timestamps {
+			word := Word{
+				Text:    ibmTimestamp[0].(string),
+				Start:   ibmTimestamp[1].(float64),
+				End:     ibmTimestamp[2].(float64),
+				Speaker: speakers[wordIndex].Speaker,
+			}
+			if i < len(alternative.WordConfidence) {
+				word.Confidence = alternative.WordConfidence[i][1].(float64)
+			}
+			segment.Words[i] = word
+			wordIndex++
+		}
+		if len(segment.Words) > 0 {
+			segment.Start = segment.Words[0].Start
+			segment.End = segment.Words[len(segment.Words)-1].End
+		}
+		transcript.Segments = append(transcript.Segments, segment)
+	}
+	return transcript
+}
+
+// BestOf returns the transcript with the highest average word confidence,
+// such as to choose between the transcripts of several services. If the
+// averages are equal, the transcript with more words is chosen, and then the
+// earlier one. It returns nil if transcripts is empty.
+func BestOf(transcripts []*Transcript) *Transcript {
+	var best *Transcript
+	bestConfidence, bestWords := 0.0, 0
+	for _, t := range transcripts {
+		words := t.Words()
+		confidence := 0.0
+		for _, word := range words {
+			confidence += word.Confidence
+		}
+		if len(words) > 0 {
+			confidence /= float64(len(words))
+		}
+		if best == nil || confidence > bestConfidence || (confidence == bestConfidence && len(words) > bestWords) {
+			best, bestConfidence, bestWords = t, confidence, len(words)
+		}
+	}
+	return best
+}
+
+// defaultVoteBucket is the length, in seconds, of the time buckets of
+// VoteWords if none is given.
+const defaultVoteBucket = 0.5
+
+// VoteWords merges transcripts of the same audio, such as by several models,
+// word by word. The words are grouped into buckets of bucketSeconds by their
+// start time, and the word with the highest confidence in each bucket is kept.
+// If confidences are equal, the word of the earlier transcript is kept. A
+// bucketSeconds which is not positive uses buckets of 0.5 seconds. The merged
+// transcript has a single segment, and the metadata of the first transcript
+// which has any.
+func VoteWords(transcripts []*Transcript, bucketSeconds float64) *Transcript {
+	if bucketSeconds <= 0 {
+		bucketSeconds = defaultVoteBucket
+	}
+	merged := &Transcript{Segments: []TranscriptSegment{}}
+	best := map[int64]Word{}
+	for _, t := range transcripts {
+		if merged.Metadata == nil {
+			merged.Metadata = t.Metadata
+		}
+		for _, word := range t.Words() {
+			bucket := int64(math.Floor(word.Start / bucketSeconds))
+			if kept, ok := best[bucket]; !ok || word.Confidence > kept.Confidence {
+				best[bucket] = word
+			}
+		}
+	}
+	if len(best) == 0 {
+		return merged
+	}
+
+	buckets := make([]int64, 0, len(best))
+	for bucket := range best {
+		buckets = append(buckets, bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+
+	segment := TranscriptSegment{Words: make([]Word, len(buckets))}
+	texts := make([]string, len(buckets))
+	for i, bucket := range buckets {
+		segment.Words[i] = best[bucket]
+		texts[i] = best[bucket].Text
+		segment.Confidence += best[bucket].Confidence
+	}
+	segment.Text = NormalizeText(strings.Join(texts, " "))
+	segment.Confidence /= float64(len(buckets))
+	segment.Start = segment.Words[0].Start
+	segment.End = segment.Words[len(segment.Words)-1].End
+	merged.Segments = append(merged.Segments, segment)
+	return merged
+}
+
+// paragraphPause is the shortest pause, in seconds, which starts a new
+// paragraph.
+const paragraphPause = 2.0
+
+// ToParagraphs splits the transcript of r into paragraphs. A new paragraph
+// starts when the speaker changes, if r has speaker labels, or after a pause
+// of paragraphPause seconds. The first word of each paragraph is capitalized.
+func (r *IBMResult) ToParagraphs() []string {
+	paragraphs := []string{}
+	for _, p := range r.ToTranscript().paragraphs() {
+		paragraphs = append(paragraphs, p.Text)
+	}
+	return paragraphs
+}
+
+// paragraph is a paragraph of a transcript. Speaker is the speaker of its
+// first word, or -1 if it is unknown.
+type paragraph struct {
+	Speaker int
+	Text    string
+}
+
+// paragraphs splits the transcript into paragraphs as described by
+// IBMResult.ToParagraphs.
+func (t *Transcript) paragraphs() []paragraph {
+	paragraphs := []paragraph{}
+	current := []string{}
+	speaker := -1
+	var previous *Word
+	for _, word := range t.Words() {
+		if previous != nil {
+			speakerChanged := word.Speaker >= 0 && previous.Speaker >= 0 && word.Speaker != previous.Speaker
+			if speakerChanged || word.Start-previous.End >= paragraphPause {
+				paragraphs = append(paragraphs, paragraph{Speaker: speaker, Text: NormalizeText(strings.Join(current, " "))})
+				current = []string{}
+			}
+		}
+		text := word.Text
+		if len(current) == 0 {
+			text = capitalize(text)
+			speaker = word.Speaker
+		}
+		current = append(current, text)
+		w := word
+		previous = &w
+	}
+	if len(current) > 0 {
+		paragraphs = append(paragraphs, paragraph{Speaker: speaker, Text: NormalizeText(strings.Join(current, " "))})
+	}
+	return paragraphs
+}
+
+// capitalize returns s with its first letter in uppercase.
+func capitalize(s string) string {
+	for i, r := range s {
+		return s[:i] + string(unicode.ToUpper(r)) + s[i+utf8.RuneLen(r):]
+	}
+	return s
+}
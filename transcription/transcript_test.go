@@ -0,0 +1,190 @@
+package transcription
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIBMResultToTranscript(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{
+			{"hello", 0.5, 1.0},
+			{"there", 1.0, 1.4},
+		},
+		[]ibmWordTimestamp{
+			{"hi", 2.0, 2.5},
+		},
+	)
+	res.Results[0].Alternatives[0].OverallConfidence = 0.85
+	res.Results[0].Alternatives[0].WordConfidence = []ibmWordConfidence{{"hello", 0.9}, {"there", 0.8}}
+	res.Results[1].Alternatives[0].OverallConfidence = 0.7
+	res.Results[1].Alternatives[0].WordConfidence = []ibmWordConfidence{{"hi", 0.7}}
+	res.SpeakerLabels = []ibmSpeakerLabel{
+		{From: 0.5, To: 1.0, Speaker: 0},
+		{From: 1.0, To: 1.4, Speaker: 0},
+		{From: 2.0, To: 2.5, Speaker: 1},
+	}
+
+	transcript := res.ToTranscript()
+	assert.Equal(&Transcript{Segments: []TranscriptSegment{
+		{
+			Text:       "hello there",
+			Start:      0.5,
+			End:        1.4,
+			Confidence: 0.85,
+			Words: []Word{
+				{Text: "hello", Start: 0.5, End: 1.0, Confidence: 0.9, Speaker: 0},
+				{Text: "there", Start: 1.0, End: 1.4, Confidence: 0.8, Speaker: 0},
+			},
+		},
+		{
+			Text:       "hi",
+			Start:      2.0,
+			End:        2.5,
+			Confidence: 0.7,
+			Words: []Word{
+				{Text: "hi", Start: 2.0, End: 2.5, Confidence: 0.7, Speaker: 1},
+			},
+		},
+	}}, transcript)
+	assert.Equal("hello there hi", transcript.Text())
+	assert.Len(transcript.Words(), 3)
+}
+
+func TestTranscriptToJSONIncludesMetadata(t *testing.T) {
+	assert := assert.New(t)
+
+	transcript := newTimestampedResult([]ibmWordTimestamp{{"hello", 0.5, 1.0}}).ToTranscript()
+	transcript.Metadata = NewTranscriptMetadata("test.flac", "en-US_BroadbandModel")
+	transcript.Metadata.TranscribedAt = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	data, err := transcript.ToJSON()
+	assert.NoError(err)
+	var exported map[string]interface{}
+	assert.NoError(json.Unmarshal(data, &exported))
+	assert.Equal(map[string]interface{}{
+		"filename":       "test.flac",
+		"duration":       2.0,
+		"model":          "en-US_BroadbandModel",
+		"transcribed_at": "2017-03-01T12:00:00Z",
+	}, exported["metadata"])
+
+	assert.Contains(transcript.ToVTT(), "WEBVTT\n\nNOTE\nSource: test.flac\n")
+
+	// metadata is left out when it is not set
+	transcript.Metadata = nil
+	data, err = transcript.ToJSON()
+	assert.NoError(err)
+	assert.NotContains(string(data), "metadata")
+}
+
+func TestToParagraphs(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{{"hello", 0.5, 1.0}, {"everyone", 1.0, 1.6}},
+		// a long pause
+		[]ibmWordTimestamp{{"so", 4.0, 4.2}, {"today", 4.2, 4.8}},
+		[]ibmWordTimestamp{{"élan", 5.0, 5.4}, {"indeed", 5.4, 6.0}},
+	)
+	res.SpeakerLabels = []ibmSpeakerLabel{
+		{From: 0.5, To: 1.0, Speaker: 0},
+		{From: 1.0, To: 1.6, Speaker: 0},
+		{From: 4.0, To: 4.2, Speaker: 0},
+		{From: 4.2, To: 4.8, Speaker: 0},
+		{From: 5.0, To: 5.4, Speaker: 1},
+		{From: 5.4, To: 6.0, Speaker: 1},
+	}
+
+	assert.Equal([]string{"Hello everyone", "So today", "Élan indeed"}, res.ToParagraphs())
+
+	// without speaker labels only pauses start paragraphs
+	res.SpeakerLabels = nil
+	assert.Equal([]string{"Hello everyone", "So today élan indeed"}, res.ToParagraphs())
+	assert.Empty(new(IBMResult).ToParagraphs())
+}
+
+func TestToWordNDJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{{"hello", 0.5, 1.0}, {"there", 1.0, 1.4}},
+		[]ibmWordTimestamp{{"“hi”", 2.0, 2.5}},
+	)
+	res.Results[0].Alternatives[0].WordConfidence = []ibmWordConfidence{{"hello", 0.9}, {"there", 0.8}}
+	res.Results[1].Alternatives[0].WordConfidence = []ibmWordConfidence{{"“hi”", 0.7}}
+
+	type line struct {
+		Word       string  `json:"word"`
+		Start      float64 `json:"start"`
+		End        float64 `json:"end"`
+		Confidence float64 `json:"confidence"`
+	}
+	lines := []line{}
+	for _, data := range strings.Split(strings.TrimSuffix(res.ToWordNDJSON(), "\n"), "\n") {
+		var l line
+		assert.NoError(json.Unmarshal([]byte(data), &l), data)
+		lines = append(lines, l)
+	}
+	assert.Equal([]line{
+		{"hello", 0.5, 1.0, 0.9},
+		{"there", 1.0, 1.4, 0.8},
+		{"“hi”", 2.0, 2.5, 0.7},
+	}, lines)
+}
+
+func TestBestOf(t *testing.T) {
+	assert := assert.New(t)
+
+	transcript := func(confidences ...float64) *Transcript {
+		segment := TranscriptSegment{}
+		for _, confidence := range confidences {
+			segment.Words = append(segment.Words, Word{Text: "word", Confidence: confidence, Speaker: -1})
+		}
+		return &Transcript{Segments: []TranscriptSegment{segment}}
+	}
+
+	unsure := transcript(0.5, 0.7)
+	sure := transcript(0.9, 0.8)
+	assert.Equal(sure, BestOf([]*Transcript{unsure, sure}))
+
+	// equal confidence prefers more words
+	short := transcript(0.8)
+	long := transcript(0.8, 0.8, 0.8)
+	assert.Equal(long, BestOf([]*Transcript{short, long}))
+
+	assert.Nil(BestOf(nil))
+}
+
+func TestVoteWords(t *testing.T) {
+	assert := assert.New(t)
+
+	transcript := func(words ...Word) *Transcript {
+		return &Transcript{Segments: []TranscriptSegment{{Words: words}}}
+	}
+	broadband := transcript(
+		Word{Text: "the", Start: 0.0, End: 0.2, Confidence: 0.9, Speaker: -1},
+		Word{Text: "plains", Start: 0.5, End: 0.9, Confidence: 0.4, Speaker: -1},
+		Word{Text: "flew", Start: 1.0, End: 1.3, Confidence: 0.8, Speaker: -1},
+	)
+	narrowband := transcript(
+		Word{Text: "a", Start: 0.0, End: 0.2, Confidence: 0.6, Speaker: -1},
+		Word{Text: "planes", Start: 0.5, End: 0.9, Confidence: 0.7, Speaker: -1},
+		Word{Text: "flew", Start: 1.0, End: 1.3, Confidence: 0.8, Speaker: -1},
+	)
+
+	merged := VoteWords([]*Transcript{broadband, narrowband}, 0.5)
+	assert.Equal("the planes flew", merged.Text())
+	assert.Len(merged.Segments, 1)
+	assert.Equal(0.0, merged.Segments[0].Start)
+	assert.Equal(1.3, merged.Segments[0].End)
+	assert.InDelta(0.8, merged.Segments[0].Confidence, 1e-9)
+
+	assert.Empty(VoteWords(nil, 0.5).Segments)
+}
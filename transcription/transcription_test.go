@@ -1,3 +1,8 @@
+// These tests mock packages with withmock, so they only build when mocktest
+// runs them with the withmock tag. A plain go vet or go test skips them.
+
+//go:build withmock
+
 package transcription
 
 import (
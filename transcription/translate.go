@@ -0,0 +1,129 @@
+package transcription
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// TranslateConfig configures the translation service used by
+// TranslateTranscript.
+type TranslateConfig struct {
+	// Endpoint is the url of the translation service. The text of each segment
+	// is POSTed as {"texts": [...], "source": ..., "target": ...} and the
+	// service responds with {"translations": [...]}, in the same order.
+	Endpoint string
+	// APIKey is sent as a bearer token to Endpoint, if set.
+	APIKey string
+	// SourceLang is the language of the transcript. Defaults to en.
+	SourceLang string
+}
+
+func (cfg TranslateConfig) sourceLang() string {
+	if cfg.SourceLang != "" {
+		return cfg.SourceLang
+	}
+	return "en"
+}
+
+// TranslateTranscript returns a copy of res with the best hypothesis of each
+// final segment translated to targetLang. Each segment keeps its start and end time.
+// The words of a translated segment are spread evenly over that time, since
+// they do not line up with the spoken words. Word confidences and keywords are
+// dropped.
+func TranslateTranscript(ctx context.Context, res *IBMResult, targetLang string, cfg TranslateConfig) (*IBMResult, error) {
+	if cfg.Endpoint == "" {
+		return nil, errors.New("no translation endpoint given")
+	}
+
+	segments := []ibmResultField{}
+	texts := []string{}
+	for _, subResult := range FinalResults(res) {
+		if len(subResult.Alternatives) == 0 {
+			continue
+		}
+		segments = append(segments, subResult)
+		texts = append(texts, strings.TrimSpace(subResult.Alternatives[0].Transcript))
+	}
+	translations, err := requestTranslations(ctx, texts, targetLang, cfg)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(translations) != len(texts) {
+		return nil, errors.Errorf("translation service returned %d translations for %d segments", len(translations), len(texts))
+	}
+
+	translated := &IBMResult{SpeakerLabels: res.SpeakerLabels}
+	for i, segment := range segments {
+		best := segment.Alternatives[0]
+		start, end := resultStart(segment), resultEnd(segment)
+		words := strings.Fields(translations[i])
+		timestamps := []ibmWordTimestamp{}
+		if len(best.Timestamps) > 0 {
+			for j, word := range words {
+				wordStart := start + (end-start)*float64(j)/float64(len(words))
+				wordEnd := start + (end-start)*float64(j+1)/float64(len(words))
+				timestamps = append(timestamps, ibmWordTimestamp{word, wordStart, wordEnd})
+			}
+		}
+		transcript := ""
+		if len(words) > 0 {
+			transcript = strings.Join(words, " ") + " "
+		}
+		translated.Results = append(translated.Results, ibmResultField{
+			Alternatives: []ibmAlternativesField{
+				ibmAlternativesField{
+					Transcript:        transcript,
+					OverallConfidence: best.OverallConfidence,
+					Timestamps:        timestamps,
+				},
+			},
+			Final: segment.Final,
+		})
+	}
+	return translated, nil
+}
+
+// requestTranslations asks the service at cfg.Endpoint to translate texts.
+func requestTranslations(ctx context.Context, texts []string, targetLang string, cfg TranslateConfig) ([]string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"texts":  texts,
+		"source": cfg.sourceLang(),
+		"target": targetLang,
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	request, err := http.NewRequest("POST", cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("User-Agent", UserAgent)
+	request.Header.Set("Content-Type", "application/json")
+	if cfg.APIKey != "" {
+		request.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("translation failed with status %s", response.Status)
+	}
+
+	var translations struct {
+		Translations []string `json:"translations"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&translations); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return translations.Translations, nil
+}
@@ -0,0 +1,90 @@
+package transcription
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateTranscript(t *testing.T) {
+	assert := assert.New(t)
+
+	dictionary := map[string]string{"hello there": "hola a todos", "goodbye": "adiós"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Texts  []string `json:"texts"`
+			Source string   `json:"source"`
+			Target string   `json:"target"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		assert.Equal("en", request.Source)
+		assert.Equal("es", request.Target)
+		assert.Equal("Bearer key", r.Header.Get("Authorization"))
+		translations := []string{}
+		for _, text := range request.Texts {
+			translations = append(translations, dictionary[text])
+		}
+		json.NewEncoder(w).Encode(map[string][]string{"translations": translations})
+	}))
+	defer server.Close()
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{{"hello", 1.0, 1.5}, {"there", 1.5, 2.5}},
+		[]ibmWordTimestamp{{"goodbye", 4.0, 5.0}},
+	)
+	res.Results[0].Alternatives[0].OverallConfidence = 0.8
+
+	translated, err := TranslateTranscript(context.Background(), res, "es", TranslateConfig{Endpoint: server.URL, APIKey: "key"})
+	assert.NoError(err)
+	assert.Equal([]string{"hola a todos ", "adiós "}, transcripts(translated))
+	assert.Equal(0.8, translated.Results[0].Alternatives[0].OverallConfidence)
+
+	// segments keep their times
+	assert.Equal(1.0, resultStart(translated.Results[0]))
+	assert.Equal(2.5, resultEnd(translated.Results[0]))
+	assert.Equal(4.0, resultStart(translated.Results[1]))
+	assert.Equal(5.0, resultEnd(translated.Results[1]))
+
+	// the original result is unchanged
+	assert.Equal([]string{"hello there ", "goodbye "}, transcripts(res))
+}
+
+func TestTranslateTranscriptSkipsInterimResults(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Texts []string `json:"texts"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		assert.Equal([]string{"hello", "there"}, request.Texts)
+		json.NewEncoder(w).Encode(map[string][]string{"translations": {"hola", "a todos"}})
+	}))
+	defer server.Close()
+
+	res := newTimestampedResult(
+		[]ibmWordTimestamp{{"hello", 0.5, 1.0}},
+		[]ibmWordTimestamp{{"ther", 1.0, 1.4}},
+		[]ibmWordTimestamp{{"there", 1.0, 1.5}},
+	)
+	res.Results[1].Final = false
+
+	translated, err := TranslateTranscript(context.Background(), res, "es", TranslateConfig{Endpoint: server.URL})
+	assert.NoError(err)
+	assert.Equal([]string{"hola ", "a todos "}, transcripts(translated))
+}
+
+func TestTranslateTranscriptFailsOnServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	res := newTimestampedResult([]ibmWordTimestamp{{"hello", 1.0, 1.5}})
+	_, err := TranslateTranscript(context.Background(), res, "es", TranslateConfig{Endpoint: server.URL})
+	assert.Error(t, err)
+}
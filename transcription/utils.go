@@ -6,95 +6,35 @@ package transcription
 
 import (
 	"fmt"
-	"io"
-	"net/http"
-	"net/smtp"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
-	"strings"
 	"time"
 
 	"gopkg.in/kothar/go-backblaze.v0"
 	"gopkg.in/mgo.v2"
 
 	log "github.com/Sirupsen/logrus"
-	"github.com/jordan-wright/email"
 	"github.com/juju/errors"
 
 	"github.com/hack4impact/transcribe4all/config"
 )
 
-// SendEmail connects to an email server at host:port and sends an email from
-// address from, to address to, with subject line subject with message body.
-func SendEmail(username string, password string, host string, port int, to []string, subject string, body string) error {
-	auth := smtp.PlainAuth("", username, password, host)
-	addr := host + ":" + strconv.Itoa(port)
-
-	message := email.Email{
-		From:    username,
-		To:      to,
-		Subject: subject,
-		Text:    []byte(body),
-	}
-	if err := message.Send(addr, auth); err != nil {
-		return errors.Trace(err)
-	}
-	return nil
-}
+// UserAgent is the User-Agent header sent with every outbound request.
+var UserAgent = "transcribe4all/1.0"
 
-// ConvertAudioIntoFormat converts encoded audio into the required format.
+// ConvertAudioIntoFormat converts encoded audio into the required format with
+// DefaultConverter.
 func ConvertAudioIntoFormat(filePath, fileExt string) (string, error) {
-	// http://cmusphinx.sourceforge.net/wiki/faq
-	// -ar 16000 sets frequency to required 16khz
-	// -ac 1 sets the number of audio channels to 1
-	newPath := filePath + "." + fileExt
-	os.Remove(newPath) // If it already exists, ffmpeg will throw an error
-	cmd := exec.Command("ffmpeg", "-i", filePath, "-ar", "16000", "-ac", "1", newPath)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return "", errors.New(err.Error() + "\nCommand Output:" + string(out))
-	}
-	return newPath, nil
-}
-
-// DownloadFileFromURL locally downloads an audio file stored at url.
-func DownloadFileFromURL(url string) (string, error) {
-	// Taken from https://github.com/thbar/golang-playground/blob/master/download-files.go
-	filePath := filePathFromURL(url)
-	file, err := os.Create(filePath)
-	if err != nil {
-		return "", errors.Trace(err)
-	}
-	defer file.Close()
-
-	// Get file contents
-	response, err := http.Get(url)
+	newPath, err := DefaultConverter.Convert(filePath, fileExt)
 	if err != nil {
 		return "", errors.Trace(err)
 	}
-	defer response.Body.Close()
-
-	// Write the body to file
-	_, err = io.Copy(file, response.Body)
-	if err != nil {
-		return "", errors.Trace(err)
-	}
-
-	return filePath, nil
-}
-
-func filePathFromURL(url string) string {
-	tokens := strings.Split(url, "/")
-	filePath := tokens[len(tokens)-1]
-	filePath = strings.Split(filePath, "?")[0]
-
-	// ensure the filePath is unique by appending timestamp
-	filePath = filePath + strconv.Itoa(int(time.Now().UnixNano()))
-	return filePath
+	return newPath, nil
 }
 
 // SplitWavFile ensures that the input audio files to IBM are less than 100mb, with 5 seconds of redundancy between files.
+// The chunks are cut with DefaultConverter if it is a SegmentExtractor.
 func SplitWavFile(wavFilePath string) ([]string, error) {
 	// http://stackoverflow.com/questions/36632511/split-audio-file-into-several-files-each-below-a-size-threshold
 	// The Stack Overflow answer ultimately calculated the length of each audio chunk in seconds.
@@ -122,7 +62,7 @@ func SplitWavFile(wavFilePath string) ([]string, error) {
 			startingSecond -= 5
 		}
 		newFilePath := strconv.Itoa(i) + "_" + wavFilePath
-		if err := extractAudioSegment(wavFilePath, newFilePath, startingSecond, chunkLengthInSeconds); err != nil {
+		if err := segmentExtractor().ExtractSegment(wavFilePath, newFilePath, startingSecond, chunkLengthInSeconds); err != nil {
 			return []string{}, errors.Trace(err)
 		}
 		names[i] = newFilePath
@@ -152,23 +92,13 @@ func getNumChunks(filePath string) (int, error) {
 	return numChunks, nil
 }
 
-// extractAudioSegment uses FFMPEG to write a new audio file starting at a given time of a given length
-func extractAudioSegment(inFilePath string, outFilePath string, ss int, t int) error {
-	// -ss: starting second, -t: duration in seconds
-	cmd := exec.Command("ffmpeg", "-i", inFilePath, "-ss", strconv.Itoa(ss), "-t", strconv.Itoa(t), outFilePath)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return errors.New(err.Error() + "\nOutput:\n" + string(out))
-	}
-	return nil
-}
-
 // MakeIBMTaskFunction returns a task function for transcription using IBM transcription functions.
 // TODO(#52): Quite a lot of the transcription process could be done concurrently.
 func MakeIBMTaskFunction(audioURL string, emailAddresses []string, searchWords []string) (task func(string) error, onFailure func(string, string)) {
 	task = func(id string) error {
 		filePath, err := DownloadFileFromURL(audioURL)
 		if err != nil {
-			return errors.Trace(err)
+			return tracePhase(err)
 		}
 		defer os.Remove(filePath)
 
@@ -207,9 +137,9 @@ func MakeIBMTaskFunction(audioURL string, emailAddresses []string, searchWords [
 			log.WithField("task", id).
 				Debugf("Converted file %s to %s", wavPath, flacPath)
 
-			ibmResult, err := TranscribeWithIBM(flacPath, searchWords, config.Config.IBMUsername, config.Config.IBMPassword)
+			ibmResult, err := TranscribeWithIBM(flacPath, searchWords, config.Config.IBMUsername, config.Config.IBMPassword, IBMOptions{})
 			if err != nil {
-				return errors.Trace(err)
+				return tracePhase(err)
 			}
 			ibmResults = append(ibmResults, ibmResult)
 		}
@@ -218,7 +148,7 @@ func MakeIBMTaskFunction(audioURL string, emailAddresses []string, searchWords [
 		if len(config.Config.BackblazeAccountID) > 0 {
 			audioURL, err := UploadFileToBackblaze(filePath, config.Config.BackblazeAccountID, config.Config.BackblazeApplicationKey, config.Config.BackblazeBucket)
 			if err != nil {
-				return errors.Trace(err)
+				return tracePhase(err)
 			}
 			transcription.AudioURL = audioURL
 			log.WithField("task", id).
@@ -235,7 +165,7 @@ func MakeIBMTaskFunction(audioURL string, emailAddresses []string, searchWords [
 
 		if len(config.Config.EmailUsername) > 0 {
 			if err := SendEmail(config.Config.EmailUsername, config.Config.EmailPassword, config.Config.EmailSMTPServer, config.Config.EmailPort, emailAddresses, fmt.Sprintf("IBM Transcription %s Complete", id), "The transcript is below. It can also be found in the database."+"\n\n"+transcription.Transcript); err != nil {
-				return errors.Trace(err)
+				return tracePhase(err)
 			}
 		}
 
@@ -257,8 +187,17 @@ func MakeIBMTaskFunction(audioURL string, emailAddresses []string, searchWords [
 	return task, onFailure
 }
 
-// UploadFileToBackblaze uploads the given gile to the given backblaze bucket
+// UploadFileToBackblaze uploads the given gile to the given backblaze bucket.
+// It returns an *UploadError if the upload fails.
 func UploadFileToBackblaze(filePath string, accountID string, applicationKey string, bucketName string) (string, error) {
+	url, err := uploadFileToBackblaze(filePath, accountID, applicationKey, bucketName)
+	if err != nil {
+		return "", &UploadError{Path: filePath, Err: err}
+	}
+	return url, nil
+}
+
+func uploadFileToBackblaze(filePath string, accountID string, applicationKey string, bucketName string) (string, error) {
 	b2, err := backblaze.NewB2(backblaze.Credentials{
 		AccountID:      accountID,
 		ApplicationKey: applicationKey,
@@ -0,0 +1,123 @@
+package transcription
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/juju/errors"
+)
+
+var (
+	// webhookRetries is how many times a failed webhook post is retried.
+	webhookRetries = 3
+	// webhookRetryDelay is the delay before the first retry of a failed
+	// webhook post. The delay doubles after every retry.
+	webhookRetryDelay = 500 * time.Millisecond
+	// webhookTimeout is how long a single webhook post may take.
+	webhookTimeout = 10 * time.Second
+)
+
+// webhookQueueSize is how many final results may wait to be posted before
+// reading the results of IBM waits for the webhook.
+const webhookQueueSize = 100
+
+// webhookPost is a final result waiting to be posted.
+type webhookPost struct {
+	index  int
+	result ibmResultField
+}
+
+// webhookPoster posts final results to a webhook in order from its own
+// goroutine, so that a slow webhook does not hold up reading the results of
+// IBM.
+type webhookPoster struct {
+	ctx   context.Context
+	url   string
+	queue chan webhookPost
+	done  chan struct{}
+}
+
+func newWebhookPoster(ctx context.Context, url string) *webhookPoster {
+	p := &webhookPoster{
+		ctx:   ctx,
+		url:   url,
+		queue: make(chan webhookPost, webhookQueueSize),
+		done:  make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+func (p *webhookPoster) run() {
+	defer close(p.done)
+	for post := range p.queue {
+		if err := postToWebhook(p.ctx, p.url, post.index, post.result); err != nil {
+			log.Warnf("Could not post result %d to the webhook: %v", post.index, err)
+		}
+	}
+}
+
+// post queues result to be posted after the results before it.
+func (p *webhookPoster) post(index int, result ibmResultField) {
+	p.queue <- webhookPost{index: index, result: result}
+}
+
+// close waits until every queued result has been posted.
+func (p *webhookPoster) close() {
+	close(p.queue)
+	<-p.done
+}
+
+// postToWebhook POSTs the final result with the given index to url, retrying
+// with exponential backoff if it fails.
+func postToWebhook(ctx context.Context, url string, index int, result ibmResultField) error {
+	body, err := json.Marshal(IBMResult{ResultIndex: index, Results: []ibmResultField{result}})
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	delay := webhookRetryDelay
+	for attempt := 0; ; attempt++ {
+		err := postJSON(ctx, url, body)
+		if err == nil || attempt == webhookRetries {
+			return errors.Trace(err)
+		}
+		log.Debugf("Retrying webhook post after error: %v", err)
+
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-ctx.Done():
+			return errors.Trace(ctx.Err())
+		}
+	}
+}
+
+// postJSON POSTs body to url as JSON. It fails if the webhook does not
+// respond within webhookTimeout.
+func postJSON(ctx context.Context, url string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	request, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", UserAgent)
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return errors.Errorf("webhook responded with %s", response.Status)
+	}
+	return nil
+}
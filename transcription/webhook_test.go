@@ -0,0 +1,83 @@
+package transcription
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranscribeWithIBMPostsFinalResultsToWebhook(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(delay time.Duration) { webhookRetryDelay = delay }(webhookRetryDelay)
+	webhookRetryDelay = time.Millisecond
+
+	var mu sync.Mutex
+	requests := 0
+	posted := []*IBMResult{}
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests == 1 {
+			// the first post is retried
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		res := new(IBMResult)
+		assert.NoError(json.NewDecoder(r.Body).Decode(res))
+		assert.Equal("application/json", r.Header.Get("Content-Type"))
+		posted = append(posted, res)
+	}))
+	defer webhook.Close()
+
+	server, _ := newDroppingIBMServer(t, 0)
+	defer server.Close()
+
+	creds := IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	_, err := TranscribeWithIBMContext(context.Background(), "test.flac", nil, creds, IBMOptions{WebhookURL: webhook.URL})
+	assert.NoError(err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(3, requests)
+	if assert.Len(posted, 2) {
+		assert.Equal(0, posted[0].ResultIndex)
+		assert.Equal([]string{"one "}, transcripts(posted[0]))
+		assert.Equal(1, posted[1].ResultIndex)
+		assert.Equal([]string{"two "}, transcripts(posted[1]))
+	}
+}
+
+func TestTranscribeWithIBMDoesNotWaitForHungWebhook(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(retries int, timeout time.Duration) {
+		webhookRetries, webhookTimeout = retries, timeout
+	}(webhookRetries, webhookTimeout)
+	webhookRetries = 0
+	webhookTimeout = 50 * time.Millisecond
+
+	release := make(chan struct{})
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer webhook.Close()
+	defer close(release)
+
+	server, _ := newDroppingIBMServer(t, 0)
+	defer server.Close()
+
+	creds := IBMCredentials{Username: "user", Password: "pass", URL: server.URL}
+	res, err := TranscribeWithIBMContext(context.Background(), "test.flac", nil, creds, IBMOptions{WebhookURL: webhook.URL})
+	assert.NoError(err)
+	if assert.NotNil(res) {
+		assert.Equal([]string{"one ", "two "}, transcripts(res))
+	}
+}